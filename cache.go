@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+// --- 片段快取 ---
+//
+// 長篇內容 (例如整本有聲書) 每次只改幾段文字就要重新合成全部片段，既浪費
+// 時間也浪費 API 額度。chunkCache 把每個片段的音訊，依照
+// (後端, 片段文字, 語言, 語音, 語速, 音調, 編碼) 的 SHA-256 存到
+// cacheDir/<hash>.<音訊格式>；下次執行時，沒變動過的片段可以直接從快取讀回，只有
+// 真正新增或修改過的片段才需要呼叫 API。
+
+const defaultCacheDir = ".gtts-cache"
+
+// chunkCache 是以內容雜湊為鍵的片段音訊快取。enabled 為 false 時，
+// get 永遠 miss、put 永遠不寫入，讓呼叫端不用額外判斷就能統一走快取路徑。
+type chunkCache struct {
+	dir     string
+	enabled bool
+}
+
+// newChunkCache 依設定檔建立快取；cacheDir 預設為 .gtts-cache。
+func newChunkCache(cfg Config) *chunkCache {
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = defaultCacheDir
+	}
+	return &chunkCache{dir: dir, enabled: cfg.CacheEnabled}
+}
+
+// chunkCacheKey 計算一個片段的快取鍵：後端名稱、片段文字、語言、語音、語速與
+// 音調。各後端專屬的設定 (例如 Azure 的 style、Polly 的 engine、Google 的
+// effectsProfileId) 不在鍵裡。
+func chunkCacheKey(chunk string, cfg Config) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "provider:%s\ntext:%s\nlang:%s\nvoice:%s\nrate:%g\npitch:%g\nencoding:MP3\n",
+		providerName(cfg), chunk, cfg.LanguageCode, cfg.VoiceName, cfg.SpeakingRate, cfg.Pitch)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path 用雜湊加上實際音訊格式的副檔名組出快取檔路徑；不同後端回傳的格式不
+// 一定是 MP3 (例如 piper 回傳 WAV)，副檔名記錄真正的格式，避免快取命中時
+// 把內容誤當成 MP3 回傳。
+func (c *chunkCache) path(hash string, format tts.Format) string {
+	return filepath.Join(c.dir, hash+"."+string(format))
+}
+
+// get 回傳雜湊對應的快取音訊與它當初存入時的實際格式；第三個回傳值代表是否
+// 命中。因為存入時的格式未知，用 glob 找出 hash 對應的檔案，副檔名就是格式。
+func (c *chunkCache) get(hash string) ([]byte, tts.Format, bool) {
+	if !c.enabled {
+		return nil, "", false
+	}
+	matches, err := filepath.Glob(filepath.Join(c.dir, hash+".*"))
+	if err != nil || len(matches) == 0 {
+		return nil, "", false
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		return nil, "", false
+	}
+	format := tts.Format(strings.TrimPrefix(filepath.Ext(matches[0]), "."))
+	return data, format, true
+}
+
+// put 把合成好的音訊連同它的實際格式存進快取；快取關閉時是無動作的 no-op。
+func (c *chunkCache) put(hash string, format tts.Format, data []byte) error {
+	if !c.enabled {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("無法建立快取目錄 %s: %w", c.dir, err)
+	}
+	return os.WriteFile(c.path(hash, format), data, 0o644)
+}
+
+// cacheManifest 記錄組成某次輸出檔案的片段雜湊順序，方便日後追查這個輸出
+// 是由哪些快取片段拼起來的。
+type cacheManifest struct {
+	OutputFilename string   `json:"outputFilename"`
+	Hashes         []string `json:"hashes"`
+}
+
+// writeManifest 把這次輸出用到的雜湊順序寫進 cacheDir/manifest.json。
+func (c *chunkCache) writeManifest(outputFilename string, hashes []string) error {
+	if !c.enabled {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("無法建立快取目錄 %s: %w", c.dir, err)
+	}
+	manifest := cacheManifest{OutputFilename: outputFilename, Hashes: hashes}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.dir, "manifest.json"), data, 0o644)
+}
+
+// purgeCache 刪除整個快取目錄，供 --purge 旗標使用。
+func purgeCache(cfg Config) error {
+	dir := cfg.CacheDir
+	if dir == "" {
+		dir = defaultCacheDir
+	}
+	log.Printf("正在清除快取目錄: %s\n", dir)
+	return os.RemoveAll(dir)
+}
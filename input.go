@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// --- 結構化輸入格式 ---
+//
+// 除了純文字/SSML 之外，InputFilename 也可以是 .srt、.vtt 字幕檔，或是一份
+// 用 "# 標題" 分段的簡單章節腳本。字幕檔的每一句都是獨立合成，再依字幕的起始
+// 時間把音訊對齊（不足的時間補靜音，超出的依 cfg.SubtitleOverflowPolicy
+// 處理）；章節腳本則是每章獨立送去合成，並在 MP3 章節標記中對應一個 CHAP。
+
+// cue 是字幕檔裡的一句話，連同它該出現的時間窗。
+type cue struct {
+	StartMS int
+	EndMS   int
+	Text    string
+}
+
+// scriptChapter 是章節腳本裡的一個章節：標題與其後到下一個標題前的內文。
+type scriptChapter struct {
+	Title string
+	Body  string
+}
+
+// inputKind 依副檔名判斷輸入檔案的結構化格式；".txt" 或其他都落到 "text"，
+// 交給既有的 SSML/純文字流程處理。
+func inputKind(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".srt":
+		return "srt"
+	case ".vtt":
+		return "vtt"
+	default:
+		return "text"
+	}
+}
+
+var srtTimeRe = regexp.MustCompile(`(\d{2}):(\d{2}):(\d{2})[,.](\d{3})\s*-->\s*(\d{2}):(\d{2}):(\d{2})[,.](\d{3})`)
+
+// parseSRT 解析 SubRip (.srt) 字幕，回傳依出現順序排列的 cue 列表。
+func parseSRT(data []byte) ([]cue, error) {
+	var cues []cue
+	blocks := strings.Split(normalizeNewlines(string(data)), "\n\n")
+	for _, block := range blocks {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+		// 第一行通常是序號，找第一個符合時間碼格式的行即可，不強求序號存在。
+		timeLineIdx := -1
+		for i, l := range lines {
+			if srtTimeRe.MatchString(l) {
+				timeLineIdx = i
+				break
+			}
+		}
+		if timeLineIdx == -1 {
+			continue
+		}
+		m := srtTimeRe.FindStringSubmatch(lines[timeLineIdx])
+		start := srtTimestampToMS(m[1:5])
+		end := srtTimestampToMS(m[5:9])
+		text := strings.TrimSpace(strings.Join(lines[timeLineIdx+1:], " "))
+		if text == "" {
+			continue
+		}
+		cues = append(cues, cue{StartMS: start, EndMS: end, Text: text})
+	}
+	return cues, nil
+}
+
+func srtTimestampToMS(parts []string) int {
+	h, _ := strconv.Atoi(parts[0])
+	m, _ := strconv.Atoi(parts[1])
+	s, _ := strconv.Atoi(parts[2])
+	ms, _ := strconv.Atoi(parts[3])
+	return ((h*60+m)*60+s)*1000 + ms
+}
+
+var vttTimeRe = regexp.MustCompile(`(?:(\d{2}):)?(\d{2}):(\d{2})\.(\d{3})\s*-->\s*(?:(\d{2}):)?(\d{2}):(\d{2})\.(\d{3})`)
+
+// parseVTT 解析 WebVTT (.vtt) 字幕。和 SRT 的差異主要是時間碼用 "." 而非
+// ","，且小時欄位是選填的，以及檔案開頭有一行 "WEBVTT"。
+func parseVTT(data []byte) ([]cue, error) {
+	text := normalizeNewlines(string(data))
+	text = strings.TrimPrefix(strings.TrimSpace(text), "WEBVTT")
+
+	var cues []cue
+	blocks := strings.Split(text, "\n\n")
+	for _, block := range blocks {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		timeLineIdx := -1
+		for i, l := range lines {
+			if vttTimeRe.MatchString(l) {
+				timeLineIdx = i
+				break
+			}
+		}
+		if timeLineIdx == -1 {
+			continue
+		}
+		m := vttTimeRe.FindStringSubmatch(lines[timeLineIdx])
+		start := vttTimestampToMS(m[1], m[2], m[3], m[4])
+		end := vttTimestampToMS(m[5], m[6], m[7], m[8])
+		content := strings.TrimSpace(strings.Join(lines[timeLineIdx+1:], " "))
+		if content == "" {
+			continue
+		}
+		cues = append(cues, cue{StartMS: start, EndMS: end, Text: content})
+	}
+	return cues, nil
+}
+
+func vttTimestampToMS(hourPart, minPart, secPart, msPart string) int {
+	h := 0
+	if hourPart != "" {
+		h, _ = strconv.Atoi(hourPart)
+	}
+	m, _ := strconv.Atoi(minPart)
+	s, _ := strconv.Atoi(secPart)
+	ms, _ := strconv.Atoi(msPart)
+	return ((h*60+m)*60+s)*1000 + ms
+}
+
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+var chapterHeaderRe = regexp.MustCompile(`^#\s+(.+)$`)
+
+// parseChapterScript 把一份用 "# 標題" 分段的腳本切成章節；如果完全沒有
+// 標題列，回傳單一個沒有標題的章節，讓呼叫端可以退回原本的整篇合成流程。
+func parseChapterScript(text string) []scriptChapter {
+	var chapters []scriptChapter
+	var cur *scriptChapter
+	var body strings.Builder
+
+	flush := func() {
+		if cur != nil {
+			cur.Body = strings.TrimSpace(body.String())
+			chapters = append(chapters, *cur)
+		}
+		body.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(normalizeNewlines(text)))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := chapterHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			cur = &scriptChapter{Title: strings.TrimSpace(m[1])}
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+
+	if len(chapters) == 0 {
+		return []scriptChapter{{Title: "", Body: strings.TrimSpace(text)}}
+	}
+	return chapters
+}
+
+// formatCueSheetTimestamp 依 CUE sheet 慣用的 MM:SS:FF（影格數，75 fps）格式
+// 輸出一個毫秒時間戳。
+func formatCueSheetTimestamp(ms int) string {
+	totalFrames := ms * 75 / 1000
+	frames := totalFrames % 75
+	totalSeconds := totalFrames / 75
+	seconds := totalSeconds % 60
+	minutes := totalSeconds / 60
+	return fmt.Sprintf("%02d:%02d:%02d", minutes, seconds, frames)
+}
+
+// buildCueSheet 產生一份最小可用的 .cue 附屬檔，列出每個章節的標題與偏移。
+func buildCueSheet(title string, offsetsMS []int, titles []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TITLE \"%s\"\n", title)
+	fmt.Fprintf(&b, "FILE \"%s\" MP3\n", title)
+	for i, ms := range offsetsMS {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE \"%s\"\n", titles[i])
+		fmt.Fprintf(&b, "    INDEX 01 %s\n", formatCueSheetTimestamp(ms))
+	}
+	return b.String()
+}
+
+// warnOverflow logs once per cue when its synthesized audio runs longer
+// than the window its subtitle timing allotted it.
+func warnOverflow(index int, overBy int) {
+	log.Printf("警告：第 %d 句字幕的語音比時間窗長 %dms，將依 subtitleOverflowPolicy 處理。\n", index+1, overBy)
+}
@@ -0,0 +1,129 @@
+package mp3join
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Chapter describes one navigable chapter to embed as an ID3v2.3 CHAP frame.
+type Chapter struct {
+	Title   string // used as the chapter's TIT2 sub-frame, truncated by the caller if needed
+	StartMS int
+	EndMS   int
+}
+
+// buildID3v2Tag builds a complete ID3v2.3 tag containing one CTOC frame
+// ("toc", pointing at every chapter in order) and one CHAP frame per
+// chapter, followed by padding-free framing per the ID3v2.3 spec.
+func buildID3v2Tag(chapters []Chapter) []byte {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	var body []byte
+	elementIDs := make([]string, len(chapters))
+	for i := range chapters {
+		elementIDs[i] = fmt.Sprintf("chp%d", i)
+	}
+	body = append(body, buildCTOCFrame("toc", elementIDs)...)
+	for i, c := range chapters {
+		body = append(body, buildCHAPFrame(elementIDs[i], c)...)
+	}
+
+	header := make([]byte, 10)
+	copy(header[0:3], []byte("ID3"))
+	header[3] = 3 // version 2.3
+	header[4] = 0 // revision
+	header[5] = 0 // flags
+	putSyncsafe(header[6:10], len(body))
+
+	return append(header, body...)
+}
+
+// buildCTOCFrame builds a CTOC frame listing, in order, the element IDs of
+// every chapter. It has no parent, so the top-level flag is set.
+func buildCTOCFrame(elementID string, childIDs []string) []byte {
+	var payload []byte
+	payload = append(payload, []byte(elementID)...)
+	payload = append(payload, 0x00)
+	const topLevel = 0x02
+	const ordered = 0x01
+	payload = append(payload, byte(topLevel|ordered))
+	payload = append(payload, byte(len(childIDs)))
+	for _, id := range childIDs {
+		payload = append(payload, []byte(id)...)
+		payload = append(payload, 0x00)
+	}
+	return frameBytes("CTOC", payload)
+}
+
+// buildCHAPFrame builds a single CHAP frame for one chapter, with an
+// embedded TIT2 sub-frame carrying its title.
+func buildCHAPFrame(elementID string, c Chapter) []byte {
+	var payload []byte
+	payload = append(payload, []byte(elementID)...)
+	payload = append(payload, 0x00)
+	payload = appendUint32(payload, uint32(c.StartMS))
+	payload = appendUint32(payload, uint32(c.EndMS))
+	payload = appendUint32(payload, 0xFFFFFFFF) // start byte offset: unknown/unused
+	payload = appendUint32(payload, 0xFFFFFFFF) // end byte offset: unknown/unused
+	payload = append(payload, buildTIT2Frame(c.Title)...)
+	return frameBytes("CHAP", payload)
+}
+
+// buildTIT2Frame builds a TIT2 (title) text frame. Chapter titles come
+// straight from the source text (this codebase's own splitText treats
+// Chinese punctuation like "。"/"！"/"？" as a first-class case), so
+// ISO-8859-1 would mangle anything outside Latin-1: non-ASCII titles are
+// encoded as UTF-16 with a BOM (encoding byte 1), the one encoding every
+// ID3v2.3 reader is required to support for non-Latin-1 text.
+func buildTIT2Frame(title string) []byte {
+	if isASCII(title) {
+		payload := append([]byte{0x00}, []byte(title)...) // encoding byte 0 = ISO-8859-1
+		return frameBytes("TIT2", payload)
+	}
+	payload := append([]byte{0x01}, utf16LEWithBOM(title)...) // encoding byte 1 = UTF-16 w/ BOM
+	return frameBytes("TIT2", payload)
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > 0x7F {
+			return false
+		}
+	}
+	return true
+}
+
+// utf16LEWithBOM encodes s as UTF-16LE with a leading byte-order mark, the
+// form ID3v2.3's encoding byte 1 specifies.
+func utf16LEWithBOM(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 2+2*len(units))
+	out[0], out[1] = 0xFF, 0xFE // little-endian BOM
+	for i, u := range units {
+		out[2+2*i] = byte(u)
+		out[2+2*i+1] = byte(u >> 8)
+	}
+	return out
+}
+
+func frameBytes(id string, payload []byte) []byte {
+	frame := make([]byte, 10+len(payload))
+	copy(frame[0:4], []byte(id))
+	putUint32(frame[4:8], uint32(len(payload)))
+	// frame[8:10] flags left as zero
+	copy(frame[10:], payload)
+	return frame
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func putSyncsafe(b []byte, size int) {
+	b[0] = byte((size >> 21) & 0x7F)
+	b[1] = byte((size >> 14) & 0x7F)
+	b[2] = byte((size >> 7) & 0x7F)
+	b[3] = byte(size & 0x7F)
+}
@@ -0,0 +1,313 @@
+// Package mp3join assembles the individual MP3 byte blobs returned by a
+// text-to-speech API into a single, valid, seekable MP3 file.
+//
+// The Google Cloud Text-to-Speech API (and most other TTS back-ends) returns
+// one small, fully self-contained MP3 per request: each one usually carries
+// its own ID3v2/Xing/LAME header and sometimes a trailing ID3v1 tag. Naively
+// concatenating those blobs produces a file where every chunk boundary has a
+// stray header sitting in the middle of the audio stream, which is why some
+// players glitch or refuse to seek past the first chunk. mp3join parses the
+// MPEG frames out of each chunk, throws away the non-audio frames, and
+// rebuilds a single stream with one correct header at the front.
+package mp3join
+
+import "fmt"
+
+// Frame is one parsed MPEG audio frame (header + payload, as raw bytes ready
+// to be written out verbatim).
+type Frame struct {
+	Data            []byte
+	SampleRate      int
+	Channels        int
+	SamplesPerFrame int
+}
+
+// Duration returns how long this frame plays for, in seconds.
+func (f Frame) Duration() float64 {
+	if f.SampleRate == 0 {
+		return 0
+	}
+	return float64(f.SamplesPerFrame) / float64(f.SampleRate)
+}
+
+var bitrateTableV1L3 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1}
+var bitrateTableV2L3 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1}
+
+var sampleRateTableV1 = [4]int{44100, 48000, 32000, -1}
+var sampleRateTableV2 = [4]int{22050, 24000, 16000, -1}
+var sampleRateTableV25 = [4]int{11025, 12000, 8000, -1}
+
+// mpegHeader is the decoded form of a 4-byte MPEG audio frame header.
+type mpegHeader struct {
+	version     int // 1, 2, or 25 (for MPEG 2.5)
+	layer       int // 1, 2, or 3
+	bitrateKbps int
+	sampleRate  int
+	padding     int
+	channels    int
+	frameLen    int
+}
+
+// parseHeader decodes the 4-byte frame header at the start of b. It returns
+// ok=false if b does not start with a valid Layer III sync word.
+func parseHeader(b []byte) (h mpegHeader, ok bool) {
+	if len(b) < 4 {
+		return h, false
+	}
+	if b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return h, false
+	}
+
+	versionBits := (b[1] >> 3) & 0x03
+	layerBits := (b[1] >> 1) & 0x03
+	if layerBits != 0x01 { // we only deal with Layer III, as that's all gTTS/TTS APIs emit
+		return h, false
+	}
+	h.layer = 3
+
+	switch versionBits {
+	case 0x00:
+		h.version = 25
+	case 0x02:
+		h.version = 2
+	case 0x03:
+		h.version = 1
+	default:
+		return h, false
+	}
+
+	bitrateIdx := (b[2] >> 4) & 0x0F
+	sampleRateIdx := (b[2] >> 2) & 0x03
+	h.padding = int((b[2] >> 1) & 0x01)
+	channelMode := (b[3] >> 6) & 0x03
+	if channelMode == 0x03 {
+		h.channels = 1
+	} else {
+		h.channels = 2
+	}
+
+	if h.version == 1 {
+		h.bitrateKbps = bitrateTableV1L3[bitrateIdx]
+	} else {
+		h.bitrateKbps = bitrateTableV2L3[bitrateIdx]
+	}
+	if h.bitrateKbps <= 0 {
+		return h, false
+	}
+
+	switch h.version {
+	case 1:
+		h.sampleRate = sampleRateTableV1[sampleRateIdx]
+	case 2:
+		h.sampleRate = sampleRateTableV2[sampleRateIdx]
+	case 25:
+		h.sampleRate = sampleRateTableV25[sampleRateIdx]
+	}
+	if h.sampleRate <= 0 {
+		return h, false
+	}
+
+	samplesPerFrame := 1152
+	if h.version != 1 {
+		samplesPerFrame = 576
+	}
+	if h.version == 1 {
+		h.frameLen = 144*h.bitrateKbps*1000/h.sampleRate + h.padding
+	} else {
+		h.frameLen = 72*h.bitrateKbps*1000/h.sampleRate + h.padding
+	}
+	_ = samplesPerFrame
+
+	return h, true
+}
+
+func samplesPerFrame(h mpegHeader) int {
+	if h.version == 1 {
+		return 1152
+	}
+	return 576
+}
+
+// sampleRateAndVersion looks up which MPEG version (1, 2, or 25 for 2.5)
+// and sample-rate table index a sample rate belongs to. Callers that build
+// raw frames from scratch (the Xing header, generated silence) need this to
+// pick the right table/frame-length formula instead of assuming MPEG1 —
+// 16kHz/22.05kHz/24kHz (MPEG2) and 8kHz/11.025kHz/12kHz (MPEG2.5) are not in
+// sampleRateTableV1 at all.
+func sampleRateAndVersion(sampleRate int) (version, idx int, ok bool) {
+	for i, sr := range sampleRateTableV1 {
+		if sr == sampleRate {
+			return 1, i, true
+		}
+	}
+	for i, sr := range sampleRateTableV2 {
+		if sr == sampleRate {
+			return 2, i, true
+		}
+	}
+	for i, sr := range sampleRateTableV25 {
+		if sr == sampleRate {
+			return 25, i, true
+		}
+	}
+	return 0, 0, false
+}
+
+// versionBits returns the 2-bit MPEG version field as it's packed into byte
+// 1 of a frame header (unshifted).
+func versionBits(version int) byte {
+	switch version {
+	case 2:
+		return 0x02
+	case 25:
+		return 0x00
+	default:
+		return 0x03
+	}
+}
+
+// frameLength returns the total length in bytes (header + payload) of an
+// MPEG Layer III frame at the given version/bitrate/sample rate/padding.
+func frameLength(version, bitrateKbps, sampleRate, padding int) int {
+	if version == 1 {
+		return 144*bitrateKbps*1000/sampleRate + padding
+	}
+	return 72*bitrateKbps*1000/sampleRate + padding
+}
+
+// bitrateTableFor returns the Layer III bitrate table for the given MPEG
+// version (V1 has its own table; V2 and V2.5 share one).
+func bitrateTableFor(version int) [16]int {
+	if version == 1 {
+		return bitrateTableV1L3
+	}
+	return bitrateTableV2L3
+}
+
+// sideInfoSize is the number of bytes of side info that immediately follow
+// the 4-byte header, used to locate an embedded Xing/Info tag.
+func sideInfoSize(h mpegHeader) int {
+	if h.version == 1 {
+		if h.channels == 1 {
+			return 17
+		}
+		return 32
+	}
+	if h.channels == 1 {
+		return 9
+	}
+	return 17
+}
+
+// isVBRHeaderFrame reports whether the frame starting at b is a Xing/Info or
+// VBRI header frame: a dummy frame containing no real audio, emitted by the
+// encoder purely to carry VBR metadata for players that look for it.
+func isVBRHeaderFrame(b []byte, h mpegHeader) bool {
+	off := 4 + sideInfoSize(h)
+	if off+4 > len(b) {
+		return false
+	}
+	tag := string(b[off : off+4])
+	if tag == "Xing" || tag == "Info" {
+		return true
+	}
+	// VBRI sits right after the header, with no side-info offset.
+	if 4+4 <= len(b) && string(b[4:8]) == "VBRI" {
+		return true
+	}
+	return false
+}
+
+// StripID3v1 drops a trailing 128-byte ID3v1 tag ("TAG" + fixed fields), if
+// present.
+func StripID3v1(b []byte) []byte {
+	if len(b) >= 128 && string(b[len(b)-128:len(b)-125]) == "TAG" {
+		return b[:len(b)-128]
+	}
+	return b
+}
+
+// skipID3v2 returns b with any leading ID3v2 tag removed.
+func skipID3v2(b []byte) []byte {
+	if len(b) < 10 || string(b[0:3]) != "ID3" {
+		return b
+	}
+	size := syncsafeToInt(b[6:10])
+	end := 10 + size
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[end:]
+}
+
+func syncsafeToInt(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// ParseFrames decodes every MPEG Layer III frame out of a chunk of MP3 bytes
+// as produced by a TTS API call. It strips any leading ID3v2 tag, any
+// trailing ID3v1 tag, and drops the Xing/Info/VBRI header frame the encoder
+// may have inserted at the start (that frame carries no real audio and would
+// introduce an audible click if it were kept in the middle of the stream).
+func ParseFrames(chunk []byte) ([]Frame, error) {
+	b := skipID3v2(chunk)
+	b = StripID3v1(b)
+
+	var frames []Frame
+	first := true
+	for len(b) > 0 {
+		h, ok := parseHeader(b)
+		if !ok {
+			// Resync: some encoders pad a frame or two of silence/garbage
+			// between the ID3 tag and the first sync word.
+			idx := nextSync(b[1:])
+			if idx < 0 {
+				break
+			}
+			b = b[1+idx:]
+			continue
+		}
+		if h.frameLen <= 0 || h.frameLen > len(b) {
+			return nil, fmt.Errorf("mp3join: invalid frame length %d at offset", h.frameLen)
+		}
+		frameBytes := b[:h.frameLen]
+		if first && isVBRHeaderFrame(frameBytes, h) {
+			first = false
+			b = b[h.frameLen:]
+			continue
+		}
+		first = false
+		frames = append(frames, Frame{
+			Data:            frameBytes,
+			SampleRate:      h.sampleRate,
+			Channels:        h.channels,
+			SamplesPerFrame: samplesPerFrame(h),
+		})
+		b = b[h.frameLen:]
+	}
+	return frames, nil
+}
+
+// Duration parses chunk as a self-contained MP3 blob (the raw bytes for one
+// TTS request/response, or a fully assembled file) and returns its total
+// playback length in milliseconds.
+func Duration(chunk []byte) (int, error) {
+	frames, err := ParseFrames(chunk)
+	if err != nil {
+		return 0, err
+	}
+	totalMS := 0.0
+	for _, f := range frames {
+		totalMS += f.Duration() * 1000
+	}
+	return int(totalMS), nil
+}
+
+func nextSync(b []byte) int {
+	for i := 0; i+1 < len(b); i++ {
+		if b[i] == 0xFF && b[i+1]&0xE0 == 0xE0 {
+			return i
+		}
+	}
+	return -1
+}
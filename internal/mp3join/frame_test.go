@@ -0,0 +1,92 @@
+package mp3join
+
+import "testing"
+
+// TestParseFramesStripsHeadersAndTags exercises ParseFrames against a
+// synthetic "TTS response" blob: a leading ID3v2 tag, a Xing/Info header
+// frame (which carries no real audio and must be dropped), two real audio
+// frames, and a trailing ID3v1 tag.
+func TestParseFramesStripsHeadersAndTags(t *testing.T) {
+	const sampleRate = 44100
+	const channels = 2
+
+	xing, err := buildXingFrame(sampleRate, channels, 2, 0)
+	if err != nil {
+		t.Fatalf("buildXingFrame: %v", err)
+	}
+
+	audioFrame := silentAudioFrame(t, sampleRate, channels)
+
+	id3v2 := make([]byte, 10)
+	copy(id3v2[0:3], []byte("ID3"))
+	id3v2[3] = 3
+	putSyncsafe(id3v2[6:10], 0)
+
+	id3v1 := make([]byte, 128)
+	copy(id3v1[0:3], []byte("TAG"))
+
+	var blob []byte
+	blob = append(blob, id3v2...)
+	blob = append(blob, xing...)
+	blob = append(blob, audioFrame...)
+	blob = append(blob, audioFrame...)
+	blob = append(blob, id3v1...)
+
+	frames, err := ParseFrames(blob)
+	if err != nil {
+		t.Fatalf("ParseFrames: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 real audio frames (Xing header dropped), got %d", len(frames))
+	}
+	for i, f := range frames {
+		if f.SampleRate != sampleRate || f.Channels != channels {
+			t.Fatalf("frame %d: got sampleRate=%d channels=%d, want %d/%d", i, f.SampleRate, f.Channels, sampleRate, channels)
+		}
+	}
+}
+
+func TestDurationSumsFrames(t *testing.T) {
+	const sampleRate = 44100
+	const channels = 2
+	audioFrame := silentAudioFrame(t, sampleRate, channels)
+
+	ms, err := Duration(append(append([]byte{}, audioFrame...), audioFrame...))
+	if err != nil {
+		t.Fatalf("Duration: %v", err)
+	}
+	wantPerFrame := 1152.0 / float64(sampleRate) * 1000
+	if want := int(2 * wantPerFrame); ms < want-1 || ms > want+1 {
+		t.Fatalf("Duration = %dms, want ~%dms", ms, want)
+	}
+}
+
+// silentAudioFrame builds one minimal, valid MPEG1 Layer III frame at
+// sampleRate/channels using the lowest available bitrate, for tests that
+// just need ParseFrames/Duration to see "a real audio frame".
+func silentAudioFrame(t *testing.T, sampleRate, channels int) []byte {
+	t.Helper()
+	version, sampleRateIdx, ok := sampleRateAndVersion(sampleRate)
+	if !ok {
+		t.Fatalf("unsupported sample rate %d", sampleRate)
+	}
+	const bitrateKbps = 32
+	frameLen := frameLength(version, bitrateKbps, sampleRate, 0)
+	frame := make([]byte, frameLen)
+	frame[0] = 0xFF
+	frame[1] = 0xE0 | versionBits(version)<<3 | 0x03
+	bitrateIdx := byte(0)
+	for i, br := range bitrateTableFor(version) {
+		if br == bitrateKbps {
+			bitrateIdx = byte(i)
+			break
+		}
+	}
+	frame[2] = bitrateIdx<<4 | byte(sampleRateIdx)<<2
+	if channels == 1 {
+		frame[3] = 0xC0
+	} else {
+		frame[3] = 0x00
+	}
+	return frame
+}
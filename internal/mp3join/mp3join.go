@@ -0,0 +1,127 @@
+package mp3join
+
+import "fmt"
+
+// maxChapterTitleRunes bounds how much of a chunk's source text goes into
+// its chapter's TIT2 title.
+const maxChapterTitleRunes = 40
+
+// Join assembles the raw MP3 bytes returned for each text chunk into a
+// single playable, seekable MP3, with one chapter per chunk. chunkTexts must
+// be the same length as chunks; chunkTexts[i] is the text that produced
+// chunks[i] and is used (up to maxChapterTitleRunes) as that chunk's chapter
+// title.
+//
+// The result is: one ID3v2.3 tag (CTOC + one CHAP per chunk) followed by one
+// freshly built Xing/Info VBR header frame, followed by every real audio
+// frame from every chunk, in order, with each chunk's own
+// ID3v2/Xing/LAME/ID3v1 header and footer frames stripped out first.
+func Join(chunks [][]byte, chunkTexts []string) ([]byte, error) {
+	if len(chunks) != len(chunkTexts) {
+		return nil, fmt.Errorf("mp3join: %d chunks but %d chunk texts", len(chunks), len(chunkTexts))
+	}
+	specs := make([]ChapterSpec, len(chunks))
+	for i, t := range chunkTexts {
+		specs[i] = ChapterSpec{Title: t, ChunkCount: 1}
+	}
+	return JoinChaptered(chunks, specs)
+}
+
+// ChapterSpec groups one or more consecutive entries of the `parts` slice
+// passed to JoinChaptered into a single chapter, so a logical chapter that
+// was itself split into several TTS requests still gets exactly one CHAP
+// frame rather than one per request.
+type ChapterSpec struct {
+	Title      string
+	ChunkCount int
+}
+
+// JoinChaptered is the general form of Join: parts is the flat, ordered list
+// of raw MP3 blobs returned by the TTS backend (one per request), and
+// chapters partitions that list into logical chapters by consuming
+// ChunkCount entries of parts per chapter, in order.
+func JoinChaptered(parts [][]byte, chapters []ChapterSpec) ([]byte, error) {
+	wantParts := 0
+	for _, c := range chapters {
+		wantParts += c.ChunkCount
+	}
+	if wantParts != len(parts) {
+		return nil, fmt.Errorf("mp3join: chapters cover %d parts but got %d parts", wantParts, len(parts))
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	var allFrames []Frame
+	outChapters := make([]Chapter, 0, len(chapters))
+	elapsedMS := 0
+	partIdx := 0
+
+	for _, c := range chapters {
+		startMS := elapsedMS
+		for i := 0; i < c.ChunkCount; i++ {
+			frames, err := ParseFrames(parts[partIdx])
+			if err != nil {
+				return nil, fmt.Errorf("mp3join: part %d: %w", partIdx, err)
+			}
+			for _, f := range frames {
+				elapsedMS += int(f.Duration() * 1000)
+			}
+			allFrames = append(allFrames, frames...)
+			partIdx++
+		}
+		outChapters = append(outChapters, Chapter{
+			Title:   chapterTitle(c.Title),
+			StartMS: startMS,
+			EndMS:   elapsedMS,
+		})
+	}
+
+	return assemble(allFrames, outChapters)
+}
+
+// assemble builds the final file: an ID3v2.3 tag carrying the chapter list,
+// a freshly computed Xing/Info VBR header, then every audio frame in order.
+func assemble(allFrames []Frame, chapters []Chapter) ([]byte, error) {
+	if len(allFrames) == 0 {
+		return nil, fmt.Errorf("mp3join: no audio frames to assemble")
+	}
+
+	sampleRate := allFrames[0].SampleRate
+	channels := allFrames[0].Channels
+
+	audioBytes := 0
+	for _, f := range allFrames {
+		audioBytes += len(f.Data)
+	}
+
+	// The Xing frame's own length only depends on sampleRate/channels, not
+	// on the byteCount value it carries, so build it once to learn that
+	// length and once more with the real total (frames + bytes, header
+	// frame included in both counts).
+	placeholder, err := buildXingFrame(sampleRate, channels, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	xingFrame, err := buildXingFrame(sampleRate, channels, len(allFrames)+1, len(placeholder)+audioBytes)
+	if err != nil {
+		return nil, err
+	}
+	id3Tag := buildID3v2Tag(chapters)
+
+	out := make([]byte, 0, len(id3Tag)+len(xingFrame)+audioBytes)
+	out = append(out, id3Tag...)
+	out = append(out, xingFrame...)
+	for _, f := range allFrames {
+		out = append(out, f.Data...)
+	}
+	return out, nil
+}
+
+func chapterTitle(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxChapterTitleRunes {
+		return text
+	}
+	return string(runes[:maxChapterTitleRunes])
+}
@@ -0,0 +1,62 @@
+package mp3join
+
+// silenceBitrateKbps is the (low, fixed) bitrate used for the padding
+// frames GenerateSilence emits; the frames carry no useful audio so a small
+// footprint is all that matters.
+const silenceBitrateKbps = 32
+
+// GenerateSilence returns a run of valid MPEG Layer III frames, encoding
+// silence, covering at least durationMS milliseconds at the given
+// sampleRate/channels. Because a frame can't be split, the actual duration
+// is rounded up to the next whole frame (~26ms at 44.1kHz). sampleRate must
+// be one of the rates MPEG1/2/2.5 Layer III support — it returns nil for an
+// unrecognized rate rather than silently forcing 44.1kHz, which would
+// produce a frame that decodes at the wrong speed relative to the real
+// audio it's spliced next to.
+//
+// This is used to pad gaps between subtitle cues so the synthesized audio
+// for each cue starts at the same timestamp as its source cue.
+func GenerateSilence(durationMS int, sampleRate, channels int) []byte {
+	if durationMS <= 0 || sampleRate <= 0 {
+		return nil
+	}
+
+	version, sampleRateIdx, ok := sampleRateAndVersion(sampleRate)
+	if !ok {
+		return nil
+	}
+	bitrateTable := bitrateTableFor(version)
+	bitrateIdx := indexOfInt(bitrateTable[:], silenceBitrateKbps)
+
+	frameLen := frameLength(version, silenceBitrateKbps, sampleRate, 0)
+	h := mpegHeader{version: version}
+	samples := samplesPerFrame(h)
+	frameDurationMS := float64(samples) / float64(sampleRate) * 1000
+
+	frame := make([]byte, frameLen)
+	frame[0] = 0xFF
+	frame[1] = 0xE0 | versionBits(version)<<3 | 0x03 // sync + version + Layer III + no CRC
+	frame[2] = byte(bitrateIdx)<<4 | byte(sampleRateIdx)<<2
+	if channels == 1 {
+		frame[3] = 0xC0
+	} else {
+		frame[3] = 0x00
+	}
+	// The rest of the frame is left zeroed: not mathematically silent PCM
+	// once decoded, but close enough for gap-filling between spoken cues.
+
+	var out []byte
+	for total := 0.0; total < float64(durationMS); total += frameDurationMS {
+		out = append(out, frame...)
+	}
+	return out
+}
+
+func indexOfInt(table []int, v int) int {
+	for i, x := range table {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
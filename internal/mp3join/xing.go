@@ -0,0 +1,113 @@
+package mp3join
+
+import "fmt"
+
+// buildXingFrame produces a single MPEG Layer III frame carrying a Xing/Info
+// VBR header: a silent frame (players skip it when they see the tag) that
+// tells the decoder the total frame count, total byte count, and a rough
+// seek TOC (table of contents) for the whole stream that follows.
+//
+// sampleRate/channels are taken from the first real audio frame so the
+// header frame itself decodes at the right rate; frameCount/byteCount are
+// written as given (assemble passes totals that include this header frame
+// itself). sampleRate must be one of the rates
+// MPEG1/2/2.5 Layer III support (e.g. 16kHz, which Azure emits, is MPEG2,
+// not MPEG1) — an unrecognized rate is an error rather than a silent
+// fallback to 44.1kHz, which would desync the header from the real audio.
+func buildXingFrame(sampleRate, channels, frameCount, byteCount int) ([]byte, error) {
+	version, sampleRateIdx, ok := sampleRateAndVersion(sampleRate)
+	if !ok {
+		return nil, fmt.Errorf("mp3join: unsupported sample rate %d for Xing header", sampleRate)
+	}
+
+	h := mpegHeader{version: version, channels: channels}
+	if channels == 1 {
+		h.channels = 1
+	} else {
+		h.channels = 2
+	}
+	sideInfo := sideInfoSize(h)
+
+	// The frame must hold the Xing tag, its flags/counts, and a 100-entry
+	// TOC right after the header+side-info. A fixed 32kbps frame (the
+	// original approach) is too small at several supported rates — e.g.
+	// 44.1/48kHz stereo needs ~150 bytes but 32kbps only gives ~105 —
+	// which panicked with an out-of-range write. Pick the lowest bitrate
+	// (bumping padding first, then bitrate) whose frame length actually
+	// covers that, so the header's own bitrate field stays truthful about
+	// how many bytes a decoder should skip to find the next frame.
+	const tagSize = 4 + 4 + 4 + 4 + 100 // "Xing" + flags + frameCount + byteCount + TOC
+	needed := 4 + sideInfo + tagSize
+
+	headerBitrateKbps, padding, frameLen, ok := pickHeaderBitrate(version, sampleRate, needed)
+	if !ok {
+		return nil, fmt.Errorf("mp3join: no bitrate large enough to hold a Xing header at %dHz", sampleRate)
+	}
+
+	frame := make([]byte, frameLen)
+	frame[0] = 0xFF
+	frame[1] = 0xE0 | versionBits(version)<<3 | 0x03 // sync + version + Layer III + no CRC
+	bitrateIdx := byte(0)
+	for i, br := range bitrateTableFor(version) {
+		if br == headerBitrateKbps {
+			bitrateIdx = byte(i)
+			break
+		}
+	}
+	frame[2] = bitrateIdx<<4 | byte(sampleRateIdx)<<2 | byte(padding)<<1
+	if channels == 1 {
+		frame[3] = 0xC0 // mono
+	} else {
+		frame[3] = 0x00 // stereo
+	}
+
+	off := 4 + sideInfo
+	copy(frame[off:], []byte("Xing"))
+	off += 4
+
+	// Flags: frames field present (0x0001) + bytes field present (0x0002) +
+	// TOC present (0x0004).
+	putUint32(frame[off:], 0x00000007)
+	off += 4
+	putUint32(frame[off:], uint32(frameCount))
+	off += 4
+	putUint32(frame[off:], uint32(byteCount))
+	off += 4
+
+	// TOC: 100 entries, each a byte giving the percentage (0-255) of the
+	// file's bytes that precede that percentage of the playback time. We
+	// don't have per-frame bit-reservoir data at this point, so we emit the
+	// standard linear approximation used by encoders that can't compute an
+	// exact TOC either; it's accurate enough for seeking, just not
+	// frame-perfect.
+	for i := 0; i < 100; i++ {
+		frame[off+i] = byte(i * 256 / 100)
+	}
+
+	return frame, nil
+}
+
+// pickHeaderBitrate finds the lowest (bitrate, padding) pair whose resulting
+// frame length is at least needed bytes, so the Xing tag fits without lying
+// about the frame's own size in its header.
+func pickHeaderBitrate(version, sampleRate, needed int) (bitrateKbps, padding, frameLen int, ok bool) {
+	for _, kbps := range bitrateTableFor(version) {
+		if kbps <= 0 {
+			continue
+		}
+		for _, p := range [2]int{0, 1} {
+			length := frameLength(version, kbps, sampleRate, p)
+			if length >= needed {
+				return kbps, p, length, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+func putUint32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
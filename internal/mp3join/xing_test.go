@@ -0,0 +1,38 @@
+package mp3join
+
+import "testing"
+
+// TestBuildXingFrameAllSampleRates covers every sample rate supported by
+// sampleRateAndVersion (spanning all three MPEG versions) to make sure the
+// Xing tag + 100-entry TOC always fits inside the frame buffer instead of
+// panicking with an out-of-range write.
+func TestBuildXingFrameAllSampleRates(t *testing.T) {
+	sampleRates := []int{44100, 48000, 32000, 22050, 24000, 16000, 11025, 12000, 8000}
+	for _, sr := range sampleRates {
+		for _, channels := range []int{1, 2} {
+			frame, err := buildXingFrame(sr, channels, 100, 123456)
+			if err != nil {
+				t.Fatalf("buildXingFrame(%d, %d): %v", sr, channels, err)
+			}
+
+			h, ok := parseHeader(frame)
+			if !ok {
+				t.Fatalf("buildXingFrame(%d, %d): produced frame has no valid header", sr, channels)
+			}
+			if h.frameLen != len(frame) {
+				t.Fatalf("buildXingFrame(%d, %d): header claims frameLen %d but frame is %d bytes", sr, channels, h.frameLen, len(frame))
+			}
+
+			off := 4 + sideInfoSize(mpegHeader{version: h.version, channels: h.channels})
+			if off+4 > len(frame) || string(frame[off:off+4]) != "Xing" {
+				t.Fatalf("buildXingFrame(%d, %d): missing Xing tag at offset %d", sr, channels, off)
+			}
+		}
+	}
+}
+
+func TestBuildXingFrameUnsupportedSampleRate(t *testing.T) {
+	if _, err := buildXingFrame(12345, 2, 1, 1); err == nil {
+		t.Fatal("expected an error for an unsupported sample rate")
+	}
+}
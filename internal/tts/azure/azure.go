@@ -0,0 +1,120 @@
+// Package azure implements tts.Synthesizer against Azure Cognitive Services
+// Speech's REST synthesis endpoint. It's registered under the provider name
+// "azure".
+package azure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+func init() {
+	tts.Register("azure", New)
+}
+
+// Config is the `azure:` section of config.yaml.
+type Config struct {
+	SubscriptionKey string `yaml:"subscriptionKey"`
+	Region          string `yaml:"region"`
+	// Style and StyleDegree select an mstts:express-as emotional speaking
+	// style (e.g. "cheerful", "sad") and its intensity (0.01-2, default 1.0
+	// when Style is set). Both are optional and only apply to voices that
+	// support express-as.
+	Style       string `yaml:"style"`
+	StyleDegree string `yaml:"styleDegree"`
+}
+
+// VoiceExtra builds the tts.VoiceParams.Extra map buildSSML reads
+// voice.Extra["style"]/["styledegree"] from.
+func (c Config) VoiceExtra() map[string]string {
+	if c.Style == "" {
+		return nil
+	}
+	return map[string]string{"style": c.Style, "styledegree": c.StyleDegree}
+}
+
+type synthesizer struct {
+	cfg    Config
+	client *http.Client
+}
+
+func New(ctx context.Context, rawConfig interface{}) (tts.Synthesizer, error) {
+	cfg, _ := rawConfig.(Config)
+	if cfg.SubscriptionKey == "" || cfg.Region == "" {
+		return nil, fmt.Errorf("azure tts: subscriptionKey and region are required")
+	}
+	return &synthesizer{cfg: cfg, client: &http.Client{}}, nil
+}
+
+func (s *synthesizer) endpoint() string {
+	return fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", s.cfg.Region)
+}
+
+// buildSSML wraps plain text into a <speak> document; an already-SSML chunk
+// is assumed to already carry its own <voice>/<prosody> elements and is
+// passed through untouched. voice.Extra["style"]/["styledegree"] select an
+// mstts:express-as emotional style when present.
+func buildSSML(chunk tts.Chunk, voice tts.VoiceParams, audio tts.AudioParams) string {
+	if chunk.SSML {
+		return chunk.Text
+	}
+
+	text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(chunk.Text)
+
+	body := fmt.Sprintf(`<prosody rate="%.2f" pitch="%+.0fst">%s</prosody>`, audio.SpeakingRate, audio.Pitch, text)
+
+	if style := voice.Extra["style"]; style != "" {
+		degree := voice.Extra["styledegree"]
+		if degree == "" {
+			degree = "1.0"
+		}
+		body = fmt.Sprintf(`<mstts:express-as style="%s" styledegree="%s">%s</mstts:express-as>`, style, degree, body)
+	}
+
+	return fmt.Sprintf(
+		`<speak version="1.0" xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		voice.LanguageCode, voice.VoiceName, body,
+	)
+}
+
+func (s *synthesizer) Synthesize(ctx context.Context, chunk tts.Chunk, voice tts.VoiceParams, audio tts.AudioParams) ([]byte, tts.Format, error) {
+	ssml := buildSSML(chunk, voice, audio)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint(), bytes.NewReader([]byte(ssml)))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", s.cfg.SubscriptionKey)
+	req.Header.Set("Content-Type", "application/ssml+xml")
+	req.Header.Set("X-Microsoft-OutputFormat", "audio-16khz-32kbitrate-mono-mp3")
+	req.Header.Set("User-Agent", "gtts")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", &tts.RetryableError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("azure tts: %s: %s", resp.Status, string(body))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			return nil, "", &tts.RetryableError{Err: err}
+		}
+		return nil, "", err
+	}
+
+	return body, tts.FormatMP3, nil
+}
+
+func (s *synthesizer) Close() error { return nil }
@@ -0,0 +1,81 @@
+// Package google implements tts.Synthesizer on top of Google Cloud
+// Text-to-Speech, i.e. the backend gtts has always used. It's registered
+// under the provider name "google".
+package google
+
+import (
+	"context"
+	"fmt"
+
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+func init() {
+	tts.Register("google", New)
+}
+
+// Config is the `google:` section of config.yaml. EffectsProfileID maps
+// straight onto AudioConfig.EffectsProfileId (e.g. "headphone-class-device").
+type Config struct {
+	EffectsProfileID []string `yaml:"effectsProfileId"`
+}
+
+type synthesizer struct {
+	client *texttospeech.Client
+	cfg    Config
+}
+
+// New builds a Google backend. rawConfig must be a Config (or the zero
+// value if the provider has no google: section).
+func New(ctx context.Context, rawConfig interface{}) (tts.Synthesizer, error) {
+	cfg, _ := rawConfig.(Config)
+	client, err := texttospeech.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("google tts: %w", err)
+	}
+	return &synthesizer{client: client, cfg: cfg}, nil
+}
+
+func (s *synthesizer) Synthesize(ctx context.Context, chunk tts.Chunk, voice tts.VoiceParams, audio tts.AudioParams) ([]byte, tts.Format, error) {
+	input := &texttospeechpb.SynthesisInput{}
+	if chunk.SSML {
+		input.InputSource = &texttospeechpb.SynthesisInput_Ssml{Ssml: chunk.Text}
+	} else {
+		input.InputSource = &texttospeechpb.SynthesisInput_Text{Text: chunk.Text}
+	}
+
+	req := &texttospeechpb.SynthesizeSpeechRequest{
+		Input: input,
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			LanguageCode: voice.LanguageCode,
+			Name:         voice.VoiceName,
+		},
+		AudioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding:    texttospeechpb.AudioEncoding_MP3,
+			SpeakingRate:     audio.SpeakingRate,
+			Pitch:            audio.Pitch,
+			EffectsProfileId: s.cfg.EffectsProfileID,
+		},
+	}
+
+	resp, err := s.client.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			switch st.Code() {
+			case codes.ResourceExhausted, codes.Unavailable, codes.DeadlineExceeded:
+				return nil, "", &tts.RetryableError{Err: err}
+			}
+		}
+		return nil, "", err
+	}
+	return resp.AudioContent, tts.FormatMP3, nil
+}
+
+func (s *synthesizer) Close() error {
+	return s.client.Close()
+}
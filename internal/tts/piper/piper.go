@@ -0,0 +1,96 @@
+// Package piper implements tts.Synthesizer by shelling out to a local
+// piper or espeak-ng binary, for offline synthesis or CI runs where no
+// cloud credentials are available. It's registered under the provider name
+// "piper".
+package piper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+func init() {
+	tts.Register("piper", New)
+}
+
+// Config is the `piper:` section of config.yaml.
+type Config struct {
+	// ExecPath is the path to the piper or espeak-ng binary. Defaults to
+	// "espeak-ng" when Backend is "espeak-ng" and to "piper" otherwise
+	// (i.e. looked up on PATH).
+	ExecPath string `yaml:"execPath"`
+	// Backend selects which CLI's argument conventions to use: "piper"
+	// (default) or "espeak-ng".
+	Backend string `yaml:"backend"`
+	// Model is a piper .onnx voice model path; ignored for espeak-ng.
+	Model string `yaml:"model"`
+}
+
+type synthesizer struct {
+	cfg Config
+}
+
+func New(ctx context.Context, rawConfig interface{}) (tts.Synthesizer, error) {
+	cfg, _ := rawConfig.(Config)
+	if cfg.ExecPath == "" {
+		if cfg.Backend == "espeak-ng" {
+			cfg.ExecPath = "espeak-ng"
+		} else {
+			cfg.ExecPath = "piper"
+		}
+	}
+	return &synthesizer{cfg: cfg}, nil
+}
+
+var ssmlTagRe = regexp.MustCompile(`<[^>]+>`)
+
+// plainText strips SSML markup for backends that only understand raw text.
+func plainText(chunk tts.Chunk) string {
+	if !chunk.SSML {
+		return chunk.Text
+	}
+	return ssmlTagRe.ReplaceAllString(chunk.Text, " ")
+}
+
+func (s *synthesizer) Synthesize(ctx context.Context, chunk tts.Chunk, voice tts.VoiceParams, audio tts.AudioParams) ([]byte, tts.Format, error) {
+	text := plainText(chunk)
+
+	var cmd *exec.Cmd
+	switch s.cfg.Backend {
+	case "espeak-ng":
+		speed := 175
+		if audio.SpeakingRate > 0 {
+			speed = int(175 * audio.SpeakingRate)
+		}
+		cmd = exec.CommandContext(ctx, s.cfg.ExecPath,
+			"-v", voice.VoiceName,
+			"-s", fmt.Sprintf("%d", speed),
+			"--stdout",
+		)
+		cmd.Stdin = bytes.NewReader([]byte(text))
+	default: // piper
+		args := []string{"--output_file", "-"}
+		if s.cfg.Model != "" {
+			args = append(args, "--model", s.cfg.Model)
+		}
+		cmd = exec.CommandContext(ctx, s.cfg.ExecPath, args...)
+		cmd.Stdin = bytes.NewReader([]byte(text))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, "", fmt.Errorf("piper tts (%s): %w: %s", s.cfg.ExecPath, err, stderr.String())
+	}
+
+	return stdout.Bytes(), tts.FormatWAV, nil
+}
+
+func (s *synthesizer) Close() error { return nil }
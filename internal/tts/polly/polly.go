@@ -0,0 +1,118 @@
+// Package polly implements tts.Synthesizer on top of Amazon Polly via
+// aws-sdk-go-v2. It's registered under the provider name "polly".
+package polly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+	"github.com/aws/aws-sdk-go-v2/service/polly/types"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+func init() {
+	tts.Register("polly", New)
+}
+
+// Config is the `polly:` section of config.yaml. Engine selects Polly's
+// synthesis engine: "standard" (default), "neural", "long-form", or
+// "generative".
+type Config struct {
+	Region string `yaml:"region"`
+	Engine string `yaml:"engine"`
+}
+
+type synthesizer struct {
+	client *polly.Client
+	cfg    Config
+}
+
+func New(ctx context.Context, rawConfig interface{}) (tts.Synthesizer, error) {
+	cfg, _ := rawConfig.(Config)
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("polly tts: %w", err)
+	}
+	return &synthesizer{client: polly.NewFromConfig(awsCfg), cfg: cfg}, nil
+}
+
+func (s *synthesizer) engine() types.Engine {
+	switch s.cfg.Engine {
+	case "neural":
+		return types.EngineNeural
+	case "long-form":
+		return types.EngineLongForm
+	case "generative":
+		return types.EngineGenerative
+	default:
+		return types.EngineStandard
+	}
+}
+
+// buildSSML wraps plain text in a <prosody> element carrying
+// audio.SpeakingRate/Pitch. An already-SSML chunk is assumed to already
+// carry its own prosody and is passed through untouched, and a chunk whose
+// rate and pitch are both unset (zero) is left as plain text since Polly
+// only reads rate/pitch off SSML's <prosody>.
+func buildSSML(chunk tts.Chunk, audio tts.AudioParams) (text string, ssml bool) {
+	if chunk.SSML {
+		return chunk.Text, true
+	}
+	if audio.SpeakingRate == 0 && audio.Pitch == 0 {
+		return chunk.Text, false
+	}
+
+	escaped := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(chunk.Text)
+	rate := audio.SpeakingRate
+	if rate <= 0 {
+		rate = 1.0
+	}
+	return fmt.Sprintf(`<speak><prosody rate="%.0f%%" pitch="%+.0fst">%s</prosody></speak>`, rate*100, audio.Pitch, escaped), true
+}
+
+func (s *synthesizer) Synthesize(ctx context.Context, chunk tts.Chunk, voice tts.VoiceParams, audio tts.AudioParams) ([]byte, tts.Format, error) {
+	text, ssml := buildSSML(chunk, audio)
+	textType := types.TextTypeText
+	if ssml {
+		textType = types.TextTypeSsml
+	}
+
+	out, err := s.client.SynthesizeSpeech(ctx, &polly.SynthesizeSpeechInput{
+		Text:         aws.String(text),
+		TextType:     textType,
+		VoiceId:      types.VoiceId(voice.VoiceName),
+		LanguageCode: types.LanguageCode(voice.LanguageCode),
+		OutputFormat: types.OutputFormatMp3,
+		Engine:       s.engine(),
+	})
+	if err != nil {
+		if isThrottling(err) {
+			return nil, "", &tts.RetryableError{Err: err}
+		}
+		return nil, "", err
+	}
+	defer out.AudioStream.Close()
+
+	data, err := io.ReadAll(out.AudioStream)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, tts.FormatMP3, nil
+}
+
+// isThrottling reports whether err came back from a Polly rate limit, so
+// the caller can back off and retry instead of giving up immediately.
+func isThrottling(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "ThrottlingException") ||
+		strings.Contains(msg, "TooManyRequestsException") ||
+		strings.Contains(msg, "ServiceUnavailableException")
+}
+
+func (s *synthesizer) Close() error { return nil }
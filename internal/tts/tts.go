@@ -0,0 +1,90 @@
+// Package tts defines a vendor-agnostic text-to-speech interface, so the
+// rest of gtts can synthesize audio without depending on any one vendor's
+// SDK. Each concrete backend (Google, Azure, Amazon Polly, local
+// piper/espeak-ng) lives in its own sub-package and registers itself here
+// under a short provider name that config.yaml's `provider:` field selects.
+package tts
+
+import (
+	"context"
+	"fmt"
+)
+
+// Format identifies the audio container/codec a Synthesizer produced.
+type Format string
+
+const (
+	FormatMP3 Format = "mp3"
+	FormatWAV Format = "wav"
+	FormatOgg Format = "ogg"
+)
+
+// Chunk is one unit of input text to synthesize.
+type Chunk struct {
+	Text string
+	// SSML reports whether Text is a complete <speak>...</speak> document
+	// rather than plain text.
+	SSML bool
+}
+
+// VoiceParams selects which voice to speak with. Extra carries settings that
+// only make sense for one provider, under keys that provider reads; today
+// only Azure's style/styledegree travel this way. Google's EffectsProfileId
+// and Polly's Engine are read from those providers' own config sections
+// instead.
+type VoiceParams struct {
+	LanguageCode string
+	VoiceName    string
+	Extra        map[string]string
+}
+
+// AudioParams are the provider-agnostic knobs every backend understands,
+// plus the same kind of per-provider Extra escape hatch as VoiceParams.
+type AudioParams struct {
+	SpeakingRate float64
+	Pitch        float64
+	Extra        map[string]string
+}
+
+// Synthesizer turns one Chunk into audio bytes. Implementations must be
+// safe for concurrent use by multiple goroutines, since gtts dispatches
+// chunks from a worker pool.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, chunk Chunk, voice VoiceParams, audio AudioParams) ([]byte, Format, error)
+	// Close releases any underlying client/connection. Safe to call even if
+	// the backend has none (no-op).
+	Close() error
+}
+
+// Factory builds a Synthesizer from a provider's own raw config section.
+// rawConfig is whatever that provider's constructor expects to type-assert
+// or unmarshal; the registry itself is deliberately untyped about it so
+// providers don't have to share a config struct.
+type Factory func(ctx context.Context, rawConfig interface{}) (Synthesizer, error)
+
+// RetryableError wraps an error a backend considers transient (rate
+// limited, temporarily unavailable, timed out) so callers can retry with
+// backoff without needing to know each vendor's own error codes.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+var registry = map[string]Factory{}
+
+// Register adds a backend under the given provider name. Called from each
+// provider sub-package's init().
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+// New looks up the backend registered under name and constructs it.
+func New(ctx context.Context, name string, rawConfig interface{}) (Synthesizer, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("tts: unknown provider %q (did you import its package for the side-effecting init()?)", name)
+	}
+	return factory(ctx, rawConfig)
+}
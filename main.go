@@ -26,15 +26,22 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"gopkg.in/yaml.v3"
 
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"github.com/woyo96827541/gtts/internal/mp3join"
+	"github.com/woyo96827541/gtts/internal/tts"
+	"github.com/woyo96827541/gtts/internal/tts/azure"
+	"github.com/woyo96827541/gtts/internal/tts/google"
+	"github.com/woyo96827541/gtts/internal/tts/piper"
+	"github.com/woyo96827541/gtts/internal/tts/polly"
 )
 
 // --- 設定區結束 ---
@@ -46,6 +53,77 @@ type Config struct {
 	MaxInputBytes  int     `yaml:"maxInputBytes"`
 	SpeakingRate   float64 `yaml:"speakingRate"`
 	Pitch          float64 `yaml:"pitch"`
+	// InputFormat 是 "text"（預設）、"ssml" 或 "chapters"。設為 ssml 時，
+	// 整份輸入檔會被當成一段 SSML 來解析與分段；text 模式下仍然可以用
+	// [[pause 500ms]]、[[rate 0.8]] 這類行內指令，會在送出前自動改寫成對應的
+	// SSML 片段。設為 chapters 時，輸入檔會依 "# 標題" 分段，每個章節獨立
+	// 合成並對應一個 MP3 CHAP 標記——必須明確選用，純文字檔裡偶然出現的
+	// "# " 開頭的行不會自動觸發這個模式。.srt/.vtt 副檔名一律當字幕處理，
+	// 不受 InputFormat 影響。
+	InputFormat string `yaml:"inputFormat"`
+
+	// Concurrency 是同時呼叫 SynthesizeSpeech 的 worker 數量，預設 4。
+	Concurrency int `yaml:"concurrency"`
+	// RequestsPerMinute、CharactersPerMinute 是可選的限流設定，對應 Google
+	// TTS 的每分鐘請求數/字元數配額；0 或未設定代表不限速。
+	RequestsPerMinute   int `yaml:"requestsPerMinute"`
+	CharactersPerMinute int `yaml:"charactersPerMinute"`
+	// FailurePolicy 決定片段合成失敗時的行為："skip"（預設，跳過該片段）、
+	// "abort"（整個流程中止）、"retryForever"（後端標為暫時性的錯誤不限次數
+	// 重試；其他錯誤不重試，該片段照樣跳過）。
+	FailurePolicy string `yaml:"failurePolicy"`
+
+	// CacheDir 是片段快取的存放目錄，預設 .gtts-cache；CacheEnabled 決定
+	// 是否啟用快取（預設關閉，維持舊有「每次都重新合成」的行為）。
+	CacheDir     string `yaml:"cacheDir"`
+	CacheEnabled bool   `yaml:"cacheEnabled"`
+
+	// SubtitleOverflowPolicy 決定 .srt/.vtt 輸入中，某句字幕的合成音訊比它
+	// 的時間窗還長時該怎麼處理："warn"（預設，記錄警告並讓它自然往後溢出）
+	// 或 "speedup"（依超出比例調高 SpeakingRate 重新合成，嘗試塞進時間窗）。
+	SubtitleOverflowPolicy string `yaml:"subtitleOverflowPolicy"`
+
+	// Provider 選擇要用哪個 TTS 後端："google"（預設，原本唯一支援的行為）、
+	// "azure"、"polly" 或 "piper"。對應後端自己的設定放在同名的區塊裡。
+	Provider string        `yaml:"provider"`
+	Google   google.Config `yaml:"google"`
+	Azure    azure.Config  `yaml:"azure"`
+	Polly    polly.Config  `yaml:"polly"`
+	Piper    piper.Config  `yaml:"piper"`
+}
+
+const defaultProvider = "google"
+
+// providerRawConfig 回傳 cfg.Provider 對應後端要吃的設定區塊，讓
+// tts.New 可以對該後端的建構子做型別斷言。
+func providerRawConfig(cfg Config) interface{} {
+	switch cfg.Provider {
+	case "azure":
+		return cfg.Azure
+	case "polly":
+		return cfg.Polly
+	case "piper":
+		return cfg.Piper
+	default:
+		return cfg.Google
+	}
+}
+
+func providerName(cfg Config) string {
+	if cfg.Provider == "" {
+		return defaultProvider
+	}
+	return cfg.Provider
+}
+
+// voiceParams 建立送給 Synthesizer 的 tts.VoiceParams，依選用的後端把該後端
+// 專屬的設定 (目前只有 Azure 的 style/styledegree) 放進 Extra。
+func voiceParams(cfg Config) tts.VoiceParams {
+	voice := tts.VoiceParams{LanguageCode: cfg.LanguageCode, VoiceName: cfg.VoiceName}
+	if providerName(cfg) == "azure" {
+		voice.Extra = cfg.Azure.VoiceExtra()
+	}
+	return voice
 }
 
 // splitText 將長文本分割成不超過 maxSize bytes 的片段
@@ -137,6 +215,9 @@ func splitText(text string, maxSize int) []string {
 }
 
 func main() {
+	purge := flag.Bool("purge", false, "清除片段快取目錄後離開，不執行合成")
+	flag.Parse()
+
 	ctx := context.Background()
 	// --- Load Configuration ---
 	configFile, err := os.ReadFile("config.yaml")
@@ -150,6 +231,14 @@ func main() {
 		log.Fatalf("無法解析設定檔 config.yaml: %v", err)
 	}
 
+	if *purge {
+		if err := purgeCache(cfg); err != nil {
+			log.Fatalf("無法清除快取: %v", err)
+		}
+		log.Println("快取已清除。")
+		return
+	}
+
 	// --- 1. 讀取輸入文字檔 ---
 	log.Printf("正在讀取輸入檔案: %s\n", cfg.InputFilename)
 	inputTextBytes, err := os.ReadFile(cfg.InputFilename)
@@ -159,66 +248,88 @@ func main() {
 	inputText := string(inputTextBytes)
 	log.Printf("讀取到 %d 個位元組。\n", len(inputTextBytes))
 
-	// --- 2. 初始化 Text-to-Speech 客戶端 ---
-	log.Println("正在初始化 Text-to-Speech 客戶端...")
-	// client, err := texttospeech.NewClient(ctx, option.WithCredentialsFile("path/to/your/keyfile.json"))
-	client, err := texttospeech.NewClient(ctx)
+	// --- 2. 初始化 TTS 後端 ---
+	provider := providerName(cfg)
+	log.Printf("正在初始化 TTS 後端: %s...\n", provider)
+	synthesizer, err := tts.New(ctx, provider, providerRawConfig(cfg))
 	if err != nil {
-		log.Fatalf("無法建立 Text-to-Speech 客戶端: %v", err)
+		log.Fatalf("無法建立 %s TTS 後端: %v", provider, err)
 	}
-	defer client.Close()
-	log.Println("Text-to-Speech 客戶端初始化完成。")
+	defer synthesizer.Close()
 
-	// --- 3. 分割文本 ---
-	textChunks := splitText(inputText, 200)
+	// --- 3. 依輸入檔案的結構化格式分派到對應的合成流程 ---
+	synthesisStart := time.Now()
+	var joined []byte
+	var cueSheet string
+	numChapters := 0
 
-	// --- 4. 準備輸出檔案 ---
-	log.Printf("準備寫入本地檔案: %s\n", cfg.OutputFilename)
-	outputFile, err := os.Create(cfg.OutputFilename)
-	if err != nil {
-		log.Fatalf("無法建立輸出檔案 %s: %v", cfg.OutputFilename, err)
-	}
-	defer outputFile.Close()
-
-	// --- 5. 逐一合成每個文本片段並寫入檔案 ---
-	log.Println("開始逐片段合成語音...")
-	totalAudioSize := 0
-	for i, chunk := range textChunks {
-		log.Printf("正在合成片段 %d / %d (%d 位元組)...\n", i+1, len(textChunks), len([]byte(chunk)))
-
-		req := &texttospeechpb.SynthesizeSpeechRequest{
-			Input: &texttospeechpb.SynthesisInput{
-				InputSource: &texttospeechpb.SynthesisInput_Text{Text: chunk},
-			},
-			Voice: &texttospeechpb.VoiceSelectionParams{
-				LanguageCode: cfg.LanguageCode,
-				Name:         cfg.VoiceName,
-			},
-			AudioConfig: &texttospeechpb.AudioConfig{
-				AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-				SpeakingRate:  cfg.SpeakingRate,
-				Pitch:         cfg.Pitch,
-			},
+	switch inputKind(cfg.InputFilename) {
+	case "srt", "vtt":
+		var cues []cue
+		if inputKind(cfg.InputFilename) == "srt" {
+			cues, err = parseSRT(inputTextBytes)
+		} else {
+			cues, err = parseVTT(inputTextBytes)
 		}
-
-		resp, err := client.SynthesizeSpeech(ctx, req)
 		if err != nil {
-			log.Printf("警告：合成片段 %d 時發生錯誤: %v\n", i+1, err)
-			// 根據需求，你可以選擇跳過這個片段 (continue) 或終止程式 (log.Fatalf)
-			continue // 這裡選擇跳過有問題的片段
+			log.Fatalf("無法解析字幕檔 %s: %v", cfg.InputFilename, err)
 		}
-
-		// 將合成的音訊資料寫入檔案
-		nBytes, err := outputFile.Write(resp.AudioContent)
+		log.Printf("字幕檔解析出 %d 句，開始逐句合成並對齊時間軸...\n", len(cues))
+		joined, cueSheet, err = runSubtitleMode(ctx, synthesizer, cfg, cues)
 		if err != nil {
-			log.Fatalf("無法將音訊資料寫入檔案 %s: %v", cfg.OutputFilename, err)
+			log.Fatalf("字幕合成流程中止: %v", err)
+		}
+		numChapters = len(cues)
+
+	default:
+		if strings.ToLower(strings.TrimSpace(cfg.InputFormat)) == "chapters" {
+			chapters := parseChapterScript(inputText)
+			log.Printf("腳本解析出 %d 個章節，開始逐章合成...\n", len(chapters))
+			joined, cueSheet, err = runChapteredMode(ctx, synthesizer, cfg, chapters)
+			if err != nil {
+				log.Fatalf("章節合成流程中止: %v", err)
+			}
+			numChapters = len(chapters)
+		} else {
+			// --- 純文字/SSML 的原有流程 ---
+			textChunks, useSSML := prepareSynthesisInput(cfg, inputText)
+			log.Printf("開始合成語音 (concurrency=%d)...\n", concurrencyOrDefault(cfg.Concurrency))
+			audioChunks, chunkTexts, format, serr := synthesizeChunks(ctx, synthesizer, cfg, textChunks, useSSML)
+			if serr != nil {
+				log.Fatalf("合成流程中止: %v", serr)
+			}
+			if format == tts.FormatMP3 {
+				log.Println("正在組裝 MP3 片段 (拆幀、重建 Xing 標頭、寫入章節標記)...")
+				joined, err = mp3join.Join(audioChunks, chunkTexts)
+				if err != nil {
+					log.Fatalf("無法組裝 MP3 片段: %v", err)
+				}
+			} else {
+				log.Printf("警告：%s 後端輸出的是 %s，非 MP3，暫不支援逐幀組裝，直接串接片段。\n", provider, format)
+				for _, chunk := range audioChunks {
+					joined = append(joined, chunk...)
+				}
+			}
+			numChapters = len(audioChunks)
+		}
+	}
+	log.Printf("全部片段合成完畢，耗時 %s。\n", time.Since(synthesisStart).Round(time.Millisecond))
+
+	// --- 4. 寫出輸出檔案 ---
+	log.Printf("準備寫入本地檔案: %s\n", cfg.OutputFilename)
+	if err := os.WriteFile(cfg.OutputFilename, joined, 0o644); err != nil {
+		log.Fatalf("無法寫入輸出檔案 %s: %v", cfg.OutputFilename, err)
+	}
+
+	if cueSheet != "" {
+		cueFilename := strings.TrimSuffix(cfg.OutputFilename, filepath.Ext(cfg.OutputFilename)) + ".cue"
+		if err := os.WriteFile(cueFilename, []byte(cueSheet), 0o644); err != nil {
+			log.Fatalf("無法寫入 CUE 附屬檔 %s: %v", cueFilename, err)
 		}
-		totalAudioSize += nBytes
-		log.Printf("片段 %d 合成完畢，寫入 %d 位元組。\n", i+1, nBytes)
+		log.Printf("已寫入 CUE 附屬檔: %s\n", cueFilename)
 	}
 
-	log.Printf("所有片段合成完成！總共寫入 %d 位元組到 %s\n", totalAudioSize, cfg.OutputFilename)
-	log.Println("注意：MP3 片段是直接串接的，可能在某些播放器或編輯器中有兼容性問題。")
+	log.Printf("所有片段合成完成！總共寫入 %d 位元組到 %s，共 %d 個章節。\n", len(joined), cfg.OutputFilename, numChapters)
 }
 
 // max 返回兩個整數中較大的那個 (輔助函數)
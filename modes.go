@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/woyo96827541/gtts/internal/mp3join"
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+// --- 字幕與章節腳本的合成流程 ---
+//
+// 這兩種輸入格式都不走「整篇文字 -> 分段 -> 依序組裝」的預設流程：字幕檔要
+// 把每句話的音訊對齊到它自己的時間窗，章節腳本則要讓每個章節標題對應一個
+// MP3 CHAP，而不是依 splitText 的任意切點。
+
+// synthesizeIndependently 平行合成 texts，回傳與輸入等長、依原始順序排列的
+// 結果；失敗的項目回傳 nil 音訊並在 errs[i] 記錄錯誤，不會讓其他項目的索引
+// 跟著位移（字幕的時間對齊與章節的章節編號都要求索引穩定）。
+func synthesizeIndependently(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, texts []string, ssml bool) (audio [][]byte, format tts.Format, errs []error) {
+	concurrency := concurrencyOrDefault(cfg.Concurrency)
+	reqLimiter := newRateLimiter(cfg.RequestsPerMinute)
+	charLimiter := newRateLimiter(cfg.CharactersPerMinute)
+	cache := newChunkCache(cfg)
+
+	audio = make([][]byte, len(texts))
+	errs = make([]error, len(texts))
+	var formatMu sync.Mutex
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	worker := func() {
+		defer wg.Done()
+		for idx := range jobs {
+			hash := chunkCacheKey(texts[idx], cfg)
+			data, f, err := synthesizeOneChunk(ctx, synthesizer, cfg, texts[idx], ssml, reqLimiter, charLimiter, cache, hash)
+			audio[idx] = data
+			errs[idx] = err
+			if err == nil {
+				formatMu.Lock()
+				format = f
+				formatMu.Unlock()
+			}
+		}
+	}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+	for i := range texts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return audio, format, errs
+}
+
+// runSubtitleMode 合成一份 SRT/VTT 字幕的每一句話，並依字幕的起始時間戳把
+// 音訊對齊：句子之間的空檔用靜音幀補上；如果某句話合成出來的音訊比它的時間
+// 窗還長，依 cfg.SubtitleOverflowPolicy 決定要加速該句 (speedup) 還是直接
+// 記錄警告讓它往後溢出 (warn，預設)。回傳組好的 MP3 與對應的 .cue 內容。
+func runSubtitleMode(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, cues []cue) (joined []byte, cueSheet string, err error) {
+	texts := make([]string, len(cues))
+	for i, c := range cues {
+		texts[i] = c.Text
+	}
+
+	audio, format, errs := synthesizeIndependently(ctx, synthesizer, cfg, texts, false)
+	if format != "" && format != tts.FormatMP3 {
+		return nil, "", fmt.Errorf("字幕模式目前只支援輸出 MP3 的後端，收到 %s", format)
+	}
+
+	var parts [][]byte
+	var specs []mp3join.ChapterSpec
+	var offsets []int
+	var titles []string
+	elapsedMS := 0
+
+	for i, c := range cues {
+		if errs[i] != nil {
+			if cfg.FailurePolicy == "abort" {
+				return nil, "", fmt.Errorf("第 %d 句字幕合成失敗: %w", i+1, errs[i])
+			}
+			log.Printf("警告：第 %d 句字幕合成失敗，已跳過並留空: %v\n", i+1, errs[i])
+			continue
+		}
+
+		chunkCount := 0
+		if gap := c.StartMS - elapsedMS; gap > 0 {
+			if sampleRate, channels, ok := firstFrameParams(audio[i]); ok {
+				parts = append(parts, mp3join.GenerateSilence(gap, sampleRate, channels))
+				chunkCount++
+			}
+			elapsedMS += gap
+		}
+
+		offsets = append(offsets, elapsedMS)
+		title := chapterLabel(c.Text)
+		titles = append(titles, title)
+
+		durationMS, err := mp3join.Duration(audio[i])
+		if err != nil {
+			log.Printf("警告：無法解析第 %d 句字幕的音訊長度: %v\n", i+1, err)
+			continue
+		}
+
+		window := c.EndMS - c.StartMS
+		if durationMS > window && window > 0 {
+			warnOverflow(i, durationMS-window)
+			if cfg.SubtitleOverflowPolicy == "speedup" {
+				faster := cfg
+				faster.SpeakingRate = cfg.SpeakingRate
+				if faster.SpeakingRate <= 0 {
+					faster.SpeakingRate = 1.0
+				}
+				faster.SpeakingRate *= float64(durationMS) / float64(window)
+				hash := chunkCacheKey(c.Text, faster)
+				redone, _, rerr := synthesizeOneChunk(ctx, synthesizer, faster, c.Text, false, nil, nil, newChunkCache(faster), hash)
+				if rerr == nil {
+					if d2, derr := mp3join.Duration(redone); derr == nil {
+						audio[i] = redone
+						durationMS = d2
+					}
+				}
+			}
+		}
+
+		parts = append(parts, audio[i])
+		chunkCount++
+		elapsedMS += durationMS
+		specs = append(specs, mp3join.ChapterSpec{Title: title, ChunkCount: chunkCount})
+	}
+
+	joined, err = mp3join.JoinChaptered(parts, specs)
+	if err != nil {
+		return nil, "", err
+	}
+	return joined, buildCueSheet(cfg.OutputFilename, offsets, titles), nil
+}
+
+func firstFrameParams(data []byte) (sampleRate, channels int, ok bool) {
+	frames, err := mp3join.ParseFrames(data)
+	if err != nil || len(frames) == 0 {
+		return 0, 0, false
+	}
+	return frames[0].SampleRate, frames[0].Channels, true
+}
+
+func chapterLabel(text string) string {
+	runes := []rune(text)
+	const max = 40
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max])
+}
+
+// runChapteredMode 合成一份以 "# 標題" 分段的腳本：每個章節各自走一次完整
+// 的 SSML/分段/併發合成流程，章節邊界對應到 mp3join 的 CHAP 標記，而不是
+// splitText 切出來的任意片段。所有章節共用同一對限流器與同一份快取，並在
+// 全部章節合成完畢後只寫一次 manifest.json，涵蓋整份輸出檔用到的所有雜湊
+// ——否則每章各自呼叫 synthesizeChunks 會各自建立限流器(等於沒有跨章節限
+// 流)，也會讓後面章節的 manifest.json 把前面章節的覆寫掉。
+func runChapteredMode(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, chapters []scriptChapter) (joined []byte, cueSheet string, err error) {
+	reqLimiter := newRateLimiter(cfg.RequestsPerMinute)
+	charLimiter := newRateLimiter(cfg.CharactersPerMinute)
+	cache := newChunkCache(cfg)
+
+	var allParts [][]byte
+	var specs []mp3join.ChapterSpec
+	var offsets []int
+	var allHashes []string
+	elapsedMS := 0
+
+	for _, ch := range chapters {
+		subChunks, useSSML := prepareSynthesisInput(cfg, ch.Body)
+		audio, chunkTexts, format, hashes, serr := synthesizeChunksShared(ctx, synthesizer, cfg, subChunks, useSSML, reqLimiter, charLimiter, cache)
+		if serr != nil {
+			return nil, "", fmt.Errorf("章節 %q 合成失敗: %w", ch.Title, serr)
+		}
+		if format != "" && format != tts.FormatMP3 {
+			return nil, "", fmt.Errorf("章節模式目前只支援輸出 MP3 的後端，收到 %s", format)
+		}
+		_ = chunkTexts
+
+		offsets = append(offsets, elapsedMS)
+		for _, part := range audio {
+			durationMS, derr := mp3join.Duration(part)
+			if derr == nil {
+				elapsedMS += durationMS
+			}
+			allParts = append(allParts, part)
+		}
+		allHashes = append(allHashes, hashes...)
+		specs = append(specs, mp3join.ChapterSpec{Title: ch.Title, ChunkCount: len(audio)})
+	}
+
+	if merr := cache.writeManifest(cfg.OutputFilename, allHashes); merr != nil {
+		log.Printf("警告：無法寫入快取 manifest: %v\n", merr)
+	}
+
+	joined, err = mp3join.JoinChaptered(allParts, specs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	titles := make([]string, len(chapters))
+	for i, ch := range chapters {
+		titles[i] = ch.Title
+	}
+	return joined, buildCueSheet(cfg.OutputFilename, offsets, titles), nil
+}
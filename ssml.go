@@ -0,0 +1,309 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- SSML 支援 ---
+//
+// 除了純文字輸入之外，config.yaml 可以設定 inputFormat: ssml，
+// 這時輸入檔會被當成一段（或多段）SSML 來處理，splitText 的 SSML 版本
+// (splitSSML) 會避免把分段切在標籤中間，並在切點處正確地關閉/重開
+// <speak>、<prosody>、<emphasis> 等元素。
+//
+// 純文字模式下也支援少量行內指令，方便使用者微調長文的語速/停頓而不用
+// 改動整份設定檔的 SpeakingRate/Pitch：
+//
+//	[[pause 500ms]]   -> <break time="500ms"/>
+//	[[rate 0.8]]      -> 後續文字包在 <prosody rate="0.8">...</prosody> 內，
+//	                     直到下一個 [[rate ...]] 或文字結尾
+
+var (
+	pauseDirectiveRe = regexp.MustCompile(`\[\[pause\s+(\d+(?:ms|s))\]\]`)
+	rateDirectiveRe  = regexp.MustCompile(`\[\[rate\s+([0-9]*\.?[0-9]+)\]\]`)
+)
+
+// hasInlineDirectives 回報文字中是否含有 [[pause ...]] 或 [[rate ...]] 指令。
+func hasInlineDirectives(text string) bool {
+	return pauseDirectiveRe.MatchString(text) || rateDirectiveRe.MatchString(text)
+}
+
+// rewriteInlineDirectives 把純文字中的行內指令改寫成一段 SSML。
+// 回傳的字串已經用 <speak> 包起來，可以直接丟進 splitSSML。
+func rewriteInlineDirectives(text string) string {
+	// 先轉義一般 XML 特殊字元，再插入我們自己的標籤，避免使用者輸入的
+	// "<" "&" 之類字元被誤判成標籤。
+	escaped := escapeSSMLText(text)
+
+	// 把 [[pause 500ms]] 換成 <break time="500ms"/>。
+	withBreaks := pauseDirectiveRe.ReplaceAllString(escaped, `<break time="$1"/>`)
+
+	// 再把 [[rate X]] 切成「一般段落」與「rate 作用段落」交錯的區段，
+	// 每個 rate 段落一路延續到下一個 [[rate ...]] 或文字結尾。
+	segments := rateDirectiveRe.Split(withBreaks, -1)
+	rates := rateDirectiveRe.FindAllStringSubmatch(withBreaks, -1)
+
+	var b strings.Builder
+	b.WriteString("<speak>")
+	b.WriteString(segments[0])
+	for i, m := range rates {
+		rate := m[1]
+		seg := ""
+		if i+1 < len(segments) {
+			seg = segments[i+1]
+		}
+		b.WriteString(`<prosody rate="`)
+		b.WriteString(rate)
+		b.WriteString(`">`)
+		b.WriteString(seg)
+		b.WriteString(`</prosody>`)
+	}
+	b.WriteString("</speak>")
+	return b.String()
+}
+
+// escapeSSMLText 轉義一般文字中的 XML 特殊字元，在插入任何我們自己的標籤
+// 之前呼叫，避免使用者輸入的內容被誤判成標籤。
+func escapeSSMLText(s string) string {
+	replacer := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+	)
+	return replacer.Replace(s)
+}
+
+// ssmlToken 代表 SSML 串流中的一個最小單位：一段標籤，或是一段純文字。
+type ssmlToken struct {
+	tag  string // 非空代表這是一個標籤，例如 "<prosody rate=\"0.8\">"
+	text string // 非空代表這是一段文字
+}
+
+var ssmlTagRe = regexp.MustCompile(`<[^>]+>`)
+
+// tokenizeSSML 把一段 SSML 切成標籤/文字交錯的 token 序列。
+func tokenizeSSML(s string) []ssmlToken {
+	var tokens []ssmlToken
+	idx := 0
+	locs := ssmlTagRe.FindAllStringIndex(s, -1)
+	for _, loc := range locs {
+		if loc[0] > idx {
+			tokens = append(tokens, ssmlToken{text: s[idx:loc[0]]})
+		}
+		tokens = append(tokens, ssmlToken{tag: s[loc[0]:loc[1]]})
+		idx = loc[1]
+	}
+	if idx < len(s) {
+		tokens = append(tokens, ssmlToken{text: s[idx:]})
+	}
+	return tokens
+}
+
+func isClosingTag(tag string) bool {
+	return strings.HasPrefix(tag, "</")
+}
+
+func isSelfClosingTag(tag string) bool {
+	return strings.HasSuffix(tag, "/>")
+}
+
+// tagName 回傳標籤的元素名稱，例如 "<prosody rate=\"0.8\">" -> "prosody"。
+func tagName(tag string) string {
+	t := strings.TrimPrefix(tag, "</")
+	t = strings.TrimPrefix(t, "<")
+	t = strings.TrimSuffix(t, "/>")
+	t = strings.TrimSuffix(t, ">")
+	if sp := strings.IndexAny(t, " \t\n"); sp != -1 {
+		t = t[:sp]
+	}
+	return t
+}
+
+// splitSSML 把一段以 <speak>...</speak> 包起來的 SSML 以大約 maxSize bytes
+// 為界切成多個片段，每個片段本身都是一段完整、可獨立送給
+// SynthesizeSpeech 的 <speak>...</speak>。累積的內容達到 maxSize 之後才會
+// 在最近的切點切開，所以片段 (連同補上的標籤) 可能超過 maxSize；單一段
+// 超過 maxSize 的文字不會再被切開。
+//
+// 規則：
+//   - 絕不在標籤中間切開。
+//   - 切點處，任何還沒關閉的元素 (<prosody>、<emphasis> 等) 會在本段結尾補上
+//     對應的關閉標籤，並在下一段開頭重新打開，讓跨段的語氣/語速設定可以延續。
+//   - 優先選在 <break.../> 之後或句尾標點之後切，避免把一句話斷在奇怪的地方。
+var speakOpenTagRe = regexp.MustCompile(`(?s)\A<speak[^>]*>`)
+
+func splitSSML(ssml string, maxSize int) []string {
+	inner := strings.TrimSpace(ssml)
+	if m := speakOpenTagRe.FindString(inner); m != "" {
+		inner = strings.TrimSuffix(inner[len(m):], "</speak>")
+	}
+
+	tokens := tokenizeSSML(inner)
+
+	var chunks []string
+	var stack []string // 目前還開著的元素標籤 (原始字串，含屬性)
+	var cur strings.Builder
+	curSize := 0
+	lastGoodBreak := -1 // cur 裡面，最後一個「適合切開」的位置 (byte offset)
+	hasText := false    // cur 裡面是否有真正的文字內容 (而不是只有標籤)
+	// breakStack 是 lastGoodBreak 當下還開著的元素標籤。
+	var breakStack []string
+
+	flush := func() {
+		if cur.Len() == 0 || !hasText {
+			cur.Reset()
+			curSize = 0
+			lastGoodBreak = -1
+			for _, tag := range stack {
+				cur.WriteString(tag)
+			}
+			curSize = cur.Len()
+			return
+		}
+		var out strings.Builder
+		out.WriteString("<speak>")
+		out.WriteString(cur.String())
+		for i := len(stack) - 1; i >= 0; i-- {
+			out.WriteString("</" + tagName(stack[i]) + ">")
+		}
+		out.WriteString("</speak>")
+		chunks = append(chunks, out.String())
+
+		cur.Reset()
+		curSize = 0
+		lastGoodBreak = -1
+		hasText = false
+		// 重新打開目前還沒關閉的元素
+		for _, tag := range stack {
+			cur.WriteString(tag)
+		}
+		curSize = cur.Len()
+	}
+
+	isSentenceEnd := func(text string) bool {
+		t := strings.TrimRight(text, " \t\n")
+		for _, p := range []string{"。", "！", "？", ".", "!", "?", "\n"} {
+			if strings.HasSuffix(t, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, tok := range tokens {
+		if tok.tag != "" {
+			cur.WriteString(tok.tag)
+			curSize += len(tok.tag)
+			if isClosingTag(tok.tag) {
+				// 彈出對應的開啟標籤
+				name := tagName(tok.tag)
+				for i := len(stack) - 1; i >= 0; i-- {
+					if tagName(stack[i]) == name {
+						stack = append(stack[:i], stack[i+1:]...)
+						break
+					}
+				}
+			} else if !isSelfClosingTag(tok.tag) {
+				stack = append(stack, tok.tag)
+			} else if strings.HasPrefix(tok.tag, "<break") {
+				// <break> 是很好的切點
+				lastGoodBreak = cur.Len()
+				breakStack = append([]string(nil), stack...)
+			}
+			continue
+		}
+
+		// 純文字：附加進目前片段，句尾位置記為候選切點。
+		text := tok.text
+		cur.WriteString(text)
+		curSize += len(text)
+		if strings.TrimSpace(text) != "" {
+			hasText = true
+		}
+		if isSentenceEnd(text) {
+			lastGoodBreak = cur.Len()
+			breakStack = append([]string(nil), stack...)
+		}
+
+		if curSize >= maxSize {
+			if lastGoodBreak > 0 {
+				remainder := cur.String()[lastGoodBreak:]
+				trimmed := cur.String()[:lastGoodBreak]
+				cur.Reset()
+				cur.WriteString(trimmed)
+				// 依切點當下的元素狀態關閉/重開；remainder 裡的標籤會再把它
+				// 帶回目前的狀態。
+				curStack := stack
+				stack = breakStack
+				flush()
+				stack = curStack
+				cur.WriteString(remainder)
+				curSize = cur.Len()
+				if strings.TrimSpace(remainder) != "" {
+					hasText = true
+				}
+			} else {
+				flush()
+			}
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// frontMatter 是輸入檔開頭、用一對 "---" 包起來的選用 YAML 區塊，目前只
+// 認得 inputFormat，讓單一檔案可以覆寫 config.yaml 的全域設定 (例如大部分
+// 輸入檔是純文字，但某一篇要用 SSML)。
+type frontMatter struct {
+	InputFormat string `yaml:"inputFormat"`
+}
+
+var frontMatterRe = regexp.MustCompile(`(?s)\A---\r?\n(.*?)\r?\n---\r?\n?`)
+
+// splitFrontMatter 把 rawText 開頭的 front-matter 區塊 (如果有的話) 拆出來，
+// 回傳解析後的 frontMatter 與剩下要送去合成的正文。沒有 front-matter 時
+// body 就是原始的 rawText。
+func splitFrontMatter(rawText string) (fm frontMatter, body string) {
+	body = rawText
+	m := frontMatterRe.FindStringSubmatch(rawText)
+	if m == nil {
+		return fm, body
+	}
+	if err := yaml.Unmarshal([]byte(m[1]), &fm); err != nil {
+		// 解析失敗就當作沒有 front-matter，把整份檔案原封不動送去合成。
+		return frontMatter{}, rawText
+	}
+	return fm, rawText[len(m[0]):]
+}
+
+// prepareSynthesisInput 依照 cfg.InputFormat 與行內指令，把讀進來的原始
+// 文字轉成送給 TTS 後端的一串片段，並回報這些片段是否為 SSML (tts.Chunk.SSML)。
+// 輸入檔開頭的 front-matter 區塊 (若有) 可以覆寫 cfg.InputFormat。
+func prepareSynthesisInput(cfg Config, rawText string) (chunks []string, ssml bool) {
+	fm, rawText := splitFrontMatter(rawText)
+
+	format := strings.ToLower(strings.TrimSpace(fm.InputFormat))
+	if format == "" {
+		format = strings.ToLower(strings.TrimSpace(cfg.InputFormat))
+	}
+
+	maxInputBytes := cfg.MaxInputBytes
+	if maxInputBytes <= 0 {
+		maxInputBytes = 200
+	}
+
+	if format == "ssml" {
+		return splitSSML(rawText, maxInputBytes), true
+	}
+
+	if hasInlineDirectives(rawText) {
+		rewritten := rewriteInlineDirectives(rawText)
+		return splitSSML(rewritten, maxInputBytes), true
+	}
+
+	return splitText(rawText, maxInputBytes), false
+}
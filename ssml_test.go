@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitSSMLStripsAttributedSpeakTag(t *testing.T) {
+	ssml := `<speak version="1.0" xml:lang="en-US">Hello <prosody rate="0.8">world</prosody>.</speak>`
+
+	chunks := splitSSML(ssml, 1000)
+	if len(chunks) != 1 {
+		t.Fatalf("expected exactly 1 chunk, got %d: %#v", len(chunks), chunks)
+	}
+
+	got := chunks[0]
+	if strings.Count(got, "<speak") != 1 {
+		t.Fatalf("expected exactly one <speak> element, got %q", got)
+	}
+	if strings.Contains(got, `<speak version="1.0" xml:lang="en-US"><speak`) {
+		t.Fatalf("root <speak ...> tag was treated as nested content: %q", got)
+	}
+	if !strings.Contains(got, "Hello") || !strings.Contains(got, "world") {
+		t.Fatalf("chunk lost text content: %q", got)
+	}
+}
+
+func TestSplitSSMLNoTrailingEmptyChunk(t *testing.T) {
+	ssml := `<speak version="1.0"><break time="500ms"/></speak>`
+
+	// splitSSML must never emit a chunk that carries no real text, even
+	// when the input is only markup (e.g. a lone <break/>).
+	chunks := splitSSML(ssml, 1000)
+	if len(chunks) != 0 {
+		t.Fatalf("expected no chunks for markup-only input with no text, got %#v", chunks)
+	}
+}
+
+func TestRewriteInlineDirectivesPause(t *testing.T) {
+	got := rewriteInlineDirectives("Hello[[pause 500ms]]world")
+	want := `<speak>Hello<break time="500ms"/>world</speak>`
+	if got != want {
+		t.Fatalf("rewriteInlineDirectives() = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteInlineDirectivesRateSpansToEnd(t *testing.T) {
+	got := rewriteInlineDirectives("normal[[rate 0.8]]slower text")
+	want := `<speak>normal<prosody rate="0.8">slower text</prosody></speak>`
+	if got != want {
+		t.Fatalf("rewriteInlineDirectives() = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteInlineDirectivesEscapesXML(t *testing.T) {
+	got := rewriteInlineDirectives("A & B < C")
+	if strings.Contains(got, "A & B") {
+		t.Fatalf("expected & to be escaped, got %q", got)
+	}
+	if !strings.Contains(got, "A &amp; B &lt; C") {
+		t.Fatalf("expected escaped text, got %q", got)
+	}
+}
+
+func TestHasInlineDirectives(t *testing.T) {
+	if !hasInlineDirectives("foo [[pause 1s]] bar") {
+		t.Fatal("expected pause directive to be detected")
+	}
+	if !hasInlineDirectives("foo [[rate 1.2]] bar") {
+		t.Fatal("expected rate directive to be detected")
+	}
+	if hasInlineDirectives("plain text, no directives") {
+		t.Fatal("expected no directives to be detected in plain text")
+	}
+}
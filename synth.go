@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/woyo96827541/gtts/internal/tts"
+)
+
+// --- 併發合成 ---
+//
+// main 過去是逐一片段序列呼叫 SynthesizeSpeech，一旦某個片段失敗就直接
+// continue 跳過，留下無聲的缺口。synthesizeChunks 改用固定大小的 worker
+// pool 平行呼叫 API，並靠一個依片段原始順序索引的結果陣列保證輸出順序不變；
+// 同時加入 token bucket 限流 (每分鐘請求數、每分鐘字元數) 與針對暫時性錯誤
+// 的指數退避重試，並依 cfg.FailurePolicy 決定失敗片段要跳過、中止整個流程、
+// 還是對暫時性錯誤不限次數地重試 (非暫時性錯誤不重試，該片段照樣跳過)。
+
+const (
+	defaultConcurrency = 4
+	maxRetryAttempts   = 5
+)
+
+// concurrencyOrDefault 回傳設定檔中的併發數，未設定 (<=0) 時回退到預設值。
+func concurrencyOrDefault(n int) int {
+	if n <= 0 {
+		return defaultConcurrency
+	}
+	return n
+}
+
+// chunkResult 是單一片段合成的結果，索引對應它在 textChunks 裡的原始順序。
+type chunkResult struct {
+	index    int
+	audio    []byte
+	format   tts.Format
+	err      error
+	duration time.Duration
+}
+
+// synthesizeChunks 平行合成 textChunks，回傳依原始順序排列、且已依
+// cfg.FailurePolicy 處理過失敗片段的音訊與對應文字，以及後端回報的音訊格式。
+// 這是單一輸出檔(一次只有一份 manifest)的進入點；每次呼叫各自建立限流器與
+// 寫一次 manifest.json。多章節的腳本（一份輸出檔、但分章節個別呼叫合成）
+// 必須改用 synthesizeChunksShared，讓限流器與 manifest 橫跨整個呼叫串列
+// 共用，見 runChapteredMode。
+func synthesizeChunks(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, textChunks []string, useSSML bool) (audioChunks [][]byte, chunkTexts []string, format tts.Format, err error) {
+	reqLimiter := newRateLimiter(cfg.RequestsPerMinute)
+	charLimiter := newRateLimiter(cfg.CharactersPerMinute)
+	cache := newChunkCache(cfg)
+
+	audioChunks, chunkTexts, format, usedHashes, err := synthesizeChunksShared(ctx, synthesizer, cfg, textChunks, useSSML, reqLimiter, charLimiter, cache)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if err := cache.writeManifest(cfg.OutputFilename, usedHashes); err != nil {
+		log.Printf("警告：無法寫入快取 manifest: %v\n", err)
+	}
+
+	return audioChunks, chunkTexts, format, nil
+}
+
+// synthesizeChunksShared is the core of synthesizeChunks, parameterized over
+// an already-built rate limiter pair and cache so callers that synthesize
+// several text-chunk batches into one output file (e.g. one call per
+// chapter) can share a single pair of limiters and accumulate the cache
+// hashes themselves instead of getting a fresh limiter (and an
+// overwritten manifest.json) per batch. It does not write the manifest;
+// callers own that.
+func synthesizeChunksShared(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, textChunks []string, useSSML bool, reqLimiter, charLimiter *rateLimiter, cache *chunkCache) (audioChunks [][]byte, chunkTexts []string, format tts.Format, usedHashes []string, err error) {
+	concurrency := concurrencyOrDefault(cfg.Concurrency)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chunkResult, len(textChunks))
+	hashes := make([]string, len(textChunks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var abortOnce sync.Once
+	var abortErr error
+
+	worker := func() {
+		defer wg.Done()
+		for idx := range jobs {
+			start := time.Now()
+			hash := chunkCacheKey(textChunks[idx], cfg)
+			hashes[idx] = hash
+			audio, audioFormat, rerr := synthesizeOneChunk(ctx, synthesizer, cfg, textChunks[idx], useSSML, reqLimiter, charLimiter, cache, hash)
+			results[idx] = chunkResult{index: idx, audio: audio, format: audioFormat, err: rerr, duration: time.Since(start)}
+			if rerr != nil && cfg.FailurePolicy == "abort" {
+				abortOnce.Do(func() {
+					abortErr = fmt.Errorf("片段 %d 合成失敗: %w", idx+1, rerr)
+					cancel()
+				})
+			}
+		}
+	}
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+
+feed:
+	for i := range textChunks {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if abortErr != nil {
+		return nil, nil, "", nil, abortErr
+	}
+
+	audioChunks = make([][]byte, 0, len(textChunks))
+	chunkTexts = make([]string, 0, len(textChunks))
+	usedHashes = make([]string, 0, len(textChunks))
+	for _, r := range results {
+		if r.err != nil {
+			log.Printf("警告：片段 %d 最終合成失敗，已跳過: %v\n", r.index+1, r.err)
+			continue
+		}
+		if r.audio == nil {
+			// 程式被取消前還沒排到的片段。
+			continue
+		}
+		log.Printf("片段 %d 合成完畢，耗時 %s，%d 位元組。\n", r.index+1, r.duration.Round(time.Millisecond), len(r.audio))
+		audioChunks = append(audioChunks, r.audio)
+		chunkTexts = append(chunkTexts, textChunks[r.index])
+		usedHashes = append(usedHashes, hashes[r.index])
+		format = r.format
+	}
+
+	return audioChunks, chunkTexts, format, usedHashes, nil
+}
+
+// synthesizeOneChunk 呼叫一次後端的 Synthesize，在遇到它回報的可重試錯誤時
+// 依 cfg.FailurePolicy 做指數退避重試。
+func synthesizeOneChunk(ctx context.Context, synthesizer tts.Synthesizer, cfg Config, chunkText string, useSSML bool, reqLimiter, charLimiter *rateLimiter, cache *chunkCache, hash string) ([]byte, tts.Format, error) {
+	if cached, format, ok := cache.get(hash); ok {
+		log.Printf("快取命中 (%s)，略過 API 呼叫。\n", hash[:12])
+		return cached, format, nil
+	}
+
+	chunk := tts.Chunk{Text: chunkText, SSML: useSSML}
+	voice := voiceParams(cfg)
+	audioParams := tts.AudioParams{SpeakingRate: cfg.SpeakingRate, Pitch: cfg.Pitch}
+
+	for attempt := 0; ; attempt++ {
+		if err := reqLimiter.wait(ctx, 1); err != nil {
+			return nil, "", err
+		}
+		if err := charLimiter.wait(ctx, float64(len([]rune(chunkText)))); err != nil {
+			return nil, "", err
+		}
+
+		audio, format, err := synthesizer.Synthesize(ctx, chunk, voice, audioParams)
+		if err == nil {
+			if cerr := cache.put(hash, format, audio); cerr != nil {
+				log.Printf("警告：無法寫入片段快取: %v\n", cerr)
+			}
+			return audio, format, nil
+		}
+
+		if !isRetryableError(err) {
+			return nil, "", err
+		}
+		if cfg.FailurePolicy != "retryForever" && attempt >= maxRetryAttempts-1 {
+			return nil, "", err
+		}
+
+		wait := backoffWithJitter(attempt)
+		log.Printf("暫時性錯誤，%s 後進行第 %d 次嘗試: %v\n", wait.Round(time.Millisecond), attempt+2, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	}
+}
+
+// isRetryableError 判斷後端是否把這個錯誤標成暫時性的 (參見
+// tts.RetryableError)，值得在退避後重試。
+func isRetryableError(err error) bool {
+	var retryable *tts.RetryableError
+	return errors.As(err, &retryable)
+}
+
+// backoffWithJitter 回傳第 attempt 次重試 (從 0 起算) 前要等待的時間：
+// 基礎時間隨次數指數成長，再疊加隨機抖動避免多個 worker 同時重試。
+func backoffWithJitter(attempt int) time.Duration {
+	base := 250 * time.Millisecond
+	// 250ms<<7 已超過 30s 上限；次數更大時直接用上限，避免位移溢位成負值。
+	backoff := 30 * time.Second
+	if attempt < 7 {
+		backoff = base << attempt
+	}
+	if backoff > 30*time.Second {
+		backoff = 30 * time.Second
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff + jitter
+}
+
+// rateLimiter 是一個簡單的 token bucket，容量與每分鐘補充速率都等於設定的
+// 每分鐘配額 (perMinute)。
+// nil 的 *rateLimiter 代表不限速。
+type rateLimiter struct {
+	mu           sync.Mutex
+	tokens       float64
+	capacity     float64
+	refillPerSec float64
+	lastRefill   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		tokens:       float64(perMinute),
+		capacity:     float64(perMinute),
+		refillPerSec: float64(perMinute) / 60.0,
+		lastRefill:   time.Now(),
+	}
+}
+
+// wait 會阻塞直到有 n 個 token 可用為止 (或 ctx 被取消)。n 大於整個桶子的
+// 容量時 (例如 charactersPerMinute 設得比單一片段的字數還小) 視為「把桶子
+// 整個耗盡」，否則 r.tokens >= n 永遠不會成立，會無限等下去。
+func (r *rateLimiter) wait(ctx context.Context, n float64) error {
+	if r == nil {
+		return nil
+	}
+	if n > r.capacity {
+		n = r.capacity
+	}
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.tokens += now.Sub(r.lastRefill).Seconds() * r.refillPerSec
+		if r.tokens > r.capacity {
+			r.tokens = r.capacity
+		}
+		r.lastRefill = now
+
+		if r.tokens >= n {
+			r.tokens -= n
+			r.mu.Unlock()
+			return nil
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}